@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRangeDays      = 7
+	maxHourlyRange        = 31 * 24 * time.Hour
+	defaultHistogramWidth = 30
+)
+
+// APIServer serves read-only analytics over HTTP for dashboards.
+type APIServer struct {
+	db         *AnalyticsDB
+	httpServer *http.Server
+}
+
+func NewAPIServer(db *AnalyticsDB, port string) *APIServer {
+	s := &APIServer{db: db}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/analytics/games", s.getGames)
+	mux.HandleFunc("/api/analytics/durations", s.getDurations)
+	mux.HandleFunc("/api/analytics/active-users", s.getActiveUsers)
+	mux.HandleFunc("/api/analytics/bot-fallback", s.getBotFallback)
+	mux.HandleFunc("/api/analytics/heatmap", s.getHeatmap)
+	mux.HandleFunc("/api/analytics/sessions", s.getSessions)
+	mux.HandleFunc("/api/analytics/churn", s.getChurn)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+	})
+
+	s.httpServer = &http.Server{
+		Addr:              ":" + port,
+		Handler:           withCORS(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+func (s *APIServer) Start() error {
+	log.Printf("Analytics API listening on %s\n", s.httpServer.Addr)
+	if err := s.httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *APIServer) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *APIServer) getGames(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRangeOrFail(w, r)
+	if !ok {
+		return
+	}
+	stats, err := s.db.GameStats(rng)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rangeResponse(rng, stats))
+}
+
+func (s *APIServer) getDurations(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRangeOrFail(w, r)
+	if !ok {
+		return
+	}
+	width := defaultHistogramWidth
+	if v := r.URL.Query().Get("bucketSeconds"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bucketSeconds must be a positive integer"})
+			return
+		}
+		width = n
+	}
+
+	histogram, err := s.db.DurationHistogram(rng, width)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":          rng.From,
+		"to":            rng.To,
+		"bucketSeconds": width,
+		"buckets":       histogram,
+	})
+}
+
+func (s *APIServer) getActiveUsers(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRangeOrFail(w, r)
+	if !ok {
+		return
+	}
+	series, total, err := s.db.ActiveUsers(rng)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	resp := rangeResponse(rng, series)
+	resp["total"] = total
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *APIServer) getBotFallback(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRangeOrFail(w, r)
+	if !ok {
+		return
+	}
+	series, err := s.db.BotFallbacks(rng)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	var requests, fallbacks int
+	for _, b := range series {
+		requests += b.Requests
+		fallbacks += b.Fallbacks
+	}
+	resp := rangeResponse(rng, series)
+	resp["requests"] = requests
+	resp["fallbacks"] = fallbacks
+	resp["rate"] = ratio(fallbacks, requests)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *APIServer) getHeatmap(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRangeOrFail(w, r)
+	if !ok {
+		return
+	}
+	heatmap, err := s.db.ColumnHeatmap(rng)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":    rng.From,
+		"to":      rng.To,
+		"moves":   heatmap.Moves,
+		"columns": heatmap.Columns,
+		"cells":   heatmap.Cells,
+	})
+}
+
+func (s *APIServer) getSessions(w http.ResponseWriter, r *http.Request) {
+	rng, ok := parseRangeOrFail(w, r)
+	if !ok {
+		return
+	}
+	series, err := s.db.SessionStats(rng)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rangeResponse(rng, series))
+}
+
+func (s *APIServer) getChurn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	churn, err := s.db.Churn()
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, churn)
+}
+
+// parseRange reads from, to and granularity. from and to take RFC 3339 or
+// YYYY-MM-DD; a plain date for to includes that whole day. The range
+// defaults to the last defaultRangeDays days, by day.
+func parseRange(r *http.Request) (Range, error) {
+	q := r.URL.Query()
+	rng := Range{Granularity: q.Get("granularity")}
+
+	switch rng.Granularity {
+	case "":
+		rng.Granularity = granularityDay
+	case granularityHour, granularityDay:
+	default:
+		return rng, fmt.Errorf("granularity must be %s or %s", granularityHour, granularityDay)
+	}
+
+	var err error
+	if rng.To, err = parseDateParam(q.Get("to"), true); err != nil {
+		return rng, fmt.Errorf("invalid to: %w", err)
+	}
+	if rng.To.IsZero() {
+		rng.To = time.Now().UTC()
+	}
+	if rng.From, err = parseDateParam(q.Get("from"), false); err != nil {
+		return rng, fmt.Errorf("invalid from: %w", err)
+	}
+	if rng.From.IsZero() {
+		rng.From = truncate(rng.To, granularityDay).AddDate(0, 0, -defaultRangeDays)
+	}
+
+	if !rng.From.Before(rng.To) {
+		return rng, errors.New("from must be before to")
+	}
+	if rng.Granularity == granularityHour && rng.To.Sub(rng.From) > maxHourlyRange {
+		return rng, errors.New("hourly ranges are limited to 31 days")
+	}
+	return rng, nil
+}
+
+func parseRangeOrFail(w http.ResponseWriter, r *http.Request) (Range, bool) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return Range{}, false
+	}
+	rng, err := parseRange(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return rng, false
+	}
+	return rng, true
+}
+
+// parseDateParam accepts an RFC 3339 timestamp or a plain YYYY-MM-DD date.
+// With endOfRange, a plain date means the end of that day.
+func parseDateParam(v string, endOfRange bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("want RFC 3339 or YYYY-MM-DD, got %q", v)
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func rangeResponse(rng Range, series interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"from":        rng.From,
+		"to":          rng.To,
+		"granularity": rng.Granularity,
+		"series":      series,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
+
+func serverError(w http.ResponseWriter, err error) {
+	log.Printf("Error querying analytics: %v\n", err)
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+}
+
+// withCORS lets a dashboard on any origin read the API. It only serves
+// aggregates, never per-player data.
+func withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDateParam(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		endOfRange bool
+		want       time.Time
+		wantErr    bool
+	}{
+		{name: "empty", value: "", want: time.Time{}},
+		{name: "empty end", value: "", endOfRange: true, want: time.Time{}},
+		{name: "date", value: "2026-03-14", want: time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{name: "date as end includes the day", value: "2026-03-14", endOfRange: true, want: time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "date at end of month", value: "2026-02-28", endOfRange: true, want: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "RFC 3339", value: "2026-03-14T09:30:00Z", want: time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)},
+		{name: "RFC 3339 as end is exact", value: "2026-03-14T09:30:00Z", endOfRange: true, want: time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)},
+		{name: "RFC 3339 with offset", value: "2026-03-14T09:30:00+02:00", want: time.Date(2026, 3, 14, 7, 30, 0, 0, time.UTC)},
+		{name: "garbage", value: "yesterday", wantErr: true},
+		{name: "no zone", value: "2026-03-14T09:30:00", wantErr: true},
+		{name: "impossible date", value: "2026-02-30", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDateParam(tt.value, tt.endOfRange)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseDateParam(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Fatalf("parseDateParam(%q, %v) = %v, want %v", tt.value, tt.endOfRange, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    Range
+		wantErr string
+	}{
+		{
+			name:  "dates",
+			query: "from=2026-03-01&to=2026-03-07",
+			want:  Range{From: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC), Granularity: granularityDay},
+		},
+		{
+			name:  "hourly timestamps",
+			query: "from=2026-03-01T10:00:00Z&to=2026-03-01T18:00:00Z&granularity=hour",
+			want:  Range{From: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), To: time.Date(2026, 3, 1, 18, 0, 0, 0, time.UTC), Granularity: granularityHour},
+		},
+		{
+			name:  "from defaults to a week before to",
+			query: "to=2026-03-07T12:00:00Z",
+			want:  Range{From: time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC), Granularity: granularityDay},
+		},
+		{
+			name:  "hourly range at the limit",
+			query: "from=2026-01-01&to=2026-01-31&granularity=hour",
+			want:  Range{From: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), Granularity: granularityHour},
+		},
+		{name: "unknown granularity", query: "granularity=week", wantErr: "granularity must be"},
+		{name: "bad from", query: "from=soon", wantErr: "invalid from"},
+		{name: "bad to", query: "to=later", wantErr: "invalid to"},
+		{name: "from after to", query: "from=2026-03-08&to=2026-03-01", wantErr: "from must be before to"},
+		{name: "empty range", query: "from=2026-03-01T00:00:00Z&to=2026-03-01T00:00:00Z", wantErr: "from must be before to"},
+		{name: "hourly range too long", query: "from=2026-01-01&to=2026-02-01&granularity=hour", wantErr: "limited to 31 days"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRange(httptest.NewRequest("GET", "/api/analytics/games?"+tt.query, nil))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseRange(%q) error = %v, want %q", tt.query, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) || got.Granularity != tt.want.Granularity {
+				t.Fatalf("parseRange(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRangeDefaults(t *testing.T) {
+	before := time.Now().UTC()
+	got, err := parseRange(httptest.NewRequest("GET", "/api/analytics/games", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Granularity != granularityDay {
+		t.Fatalf("granularity = %q, want %q", got.Granularity, granularityDay)
+	}
+	if got.To.Before(before) || got.To.After(time.Now().UTC()) {
+		t.Fatalf("to = %v, want now", got.To)
+	}
+	if want := truncate(got.To, granularityDay).AddDate(0, 0, -defaultRangeDays); !got.From.Equal(want) {
+		t.Fatalf("from = %v, want %v", got.From, want)
+	}
+}
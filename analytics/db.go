@@ -0,0 +1,176 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	_ "github.com/lib/pq"
+)
+
+// AnalyticsDB stores analytics in the DATABASE_URL database, in tables
+// prefixed analytics_ next to the backend's own.
+//
+// Every event is recorded once in analytics_events, keyed by its event ID.
+// Facts (analytics_games, analytics_moves), the aggregates and the consumer's
+// Kafka offset are written in the same transaction, so a crash or a replayed
+// event never double counts. The aggregates can always be rebuilt from the
+// facts.
+type AnalyticsDB struct {
+	conn     *sql.DB
+	sessions SessionConfig
+}
+
+// schema creates the analytics tables. Statements must be safe to run again
+// on every start.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS analytics_events (
+		event_id VARCHAR(64) PRIMARY KEY,
+		event_type VARCHAR(50) NOT NULL,
+		game_id VARCHAR(255) NOT NULL DEFAULT '',
+		player VARCHAR(255) NOT NULL DEFAULT '',
+		occurred_at TIMESTAMPTZ NOT NULL,
+		recorded_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_analytics_events_type ON analytics_events(event_type, occurred_at)`,
+	// Where each event was read from, so a replay can drop what it re-reads
+	`ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS kafka_partition INT`,
+	`ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS kafka_offset BIGINT`,
+	`CREATE INDEX IF NOT EXISTS idx_analytics_events_offset ON analytics_events(kafka_partition, kafka_offset)`,
+	`CREATE INDEX IF NOT EXISTS idx_analytics_events_occurred ON analytics_events(occurred_at)`,
+
+	// Next offset to read per partition, committed with the events before it
+	`CREATE TABLE IF NOT EXISTS analytics_offsets (
+		consumer VARCHAR(255) NOT NULL,
+		topic VARCHAR(255) NOT NULL,
+		kafka_partition INT NOT NULL,
+		next_offset BIGINT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (consumer, topic, kafka_partition)
+	)`,
+
+	// first_mover is always player 1; winner is a username, "Bot" or "draw"
+	`CREATE TABLE IF NOT EXISTS analytics_games (
+		game_id VARCHAR(255) PRIMARY KEY,
+		first_mover VARCHAR(255) NOT NULL,
+		second_mover VARCHAR(255) NOT NULL,
+		winner VARCHAR(255) NOT NULL,
+		is_bot BOOLEAN NOT NULL,
+		duration_sec INT NOT NULL,
+		completed_at TIMESTAMPTZ NOT NULL,
+		completed_hour TIMESTAMPTZ NOT NULL,
+		completed_day TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_analytics_games_completed ON analytics_games(completed_at)`,
+	// end_reason is the game_completed reason; late games started too far
+	// behind the watermark and are left out of streaming sessions
+	`ALTER TABLE analytics_games ADD COLUMN IF NOT EXISTS end_reason VARCHAR(50) NOT NULL DEFAULT ''`,
+	`ALTER TABLE analytics_games ADD COLUMN IF NOT EXISTS late BOOLEAN NOT NULL DEFAULT FALSE`,
+
+	`CREATE TABLE IF NOT EXISTS analytics_moves (
+		event_id VARCHAR(64) PRIMARY KEY,
+		game_id VARCHAR(255) NOT NULL,
+		move_number INT NOT NULL,
+		player VARCHAR(255) NOT NULL,
+		col INT NOT NULL,
+		row_index INT NOT NULL,
+		played_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_analytics_moves_game ON analytics_moves(game_id)`,
+
+	// Messages that couldn't be recorded, kept until re-driven
+	`CREATE TABLE IF NOT EXISTS analytics_dead_letters (
+		id VARCHAR(600) PRIMARY KEY,
+		consumer VARCHAR(255) NOT NULL,
+		topic VARCHAR(255) NOT NULL,
+		kafka_partition INT NOT NULL,
+		kafka_offset BIGINT NOT NULL,
+		payload BYTEA NOT NULL,
+		reason TEXT NOT NULL,
+		attempts INT NOT NULL,
+		failed_at TIMESTAMPTZ NOT NULL,
+		redriven_at TIMESTAMPTZ
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_analytics_dead_letters_pending ON analytics_dead_letters(failed_at) WHERE redriven_at IS NULL`,
+	// Payloads were TEXT, which rejects NUL bytes and invalid UTF-8
+	`DO $$ BEGIN
+		IF EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'analytics_dead_letters' AND column_name = 'payload' AND data_type = 'text'
+		) THEN
+			ALTER TABLE analytics_dead_letters ALTER COLUMN payload TYPE BYTEA USING convert_to(payload, 'UTF8');
+		END IF;
+	END $$`,
+
+	// Per-player sessions of games no more than SESSION_GAP apart. Metrics
+	// are filled in from analytics_games when a session closes.
+	`CREATE TABLE IF NOT EXISTS analytics_sessions (
+		id VARCHAR(600) PRIMARY KEY,
+		username VARCHAR(255) NOT NULL,
+		started_at TIMESTAMPTZ NOT NULL,
+		ended_at TIMESTAMPTZ NOT NULL,
+		status VARCHAR(10) NOT NULL,
+		games INT NOT NULL DEFAULT 0,
+		wins INT NOT NULL DEFAULT 0,
+		losses INT NOT NULL DEFAULT 0,
+		draws INT NOT NULL DEFAULT 0,
+		rage_quits INT NOT NULL DEFAULT 0,
+		ended_on_rage_quit BOOLEAN NOT NULL DEFAULT FALSE,
+		final_loss_streak INT NOT NULL DEFAULT 0,
+		closed_at TIMESTAMPTZ
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_analytics_sessions_player ON analytics_sessions(username, status)`,
+	`CREATE INDEX IF NOT EXISTS idx_analytics_sessions_open ON analytics_sessions(status, ended_at)`,
+
+	// Aggregates, rebuilt from analytics_games by RebuildAggregates
+	`CREATE TABLE IF NOT EXISTS analytics_game_stats (
+		granularity VARCHAR(10) NOT NULL,
+		bucket TIMESTAMPTZ NOT NULL,
+		games INT NOT NULL DEFAULT 0,
+		bot_games INT NOT NULL DEFAULT 0,
+		draws INT NOT NULL DEFAULT 0,
+		first_mover_wins INT NOT NULL DEFAULT 0,
+		total_duration_sec BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (granularity, bucket)
+	)`,
+	`CREATE TABLE IF NOT EXISTS analytics_player_activity (
+		username VARCHAR(255) PRIMARY KEY,
+		games INT NOT NULL DEFAULT 0,
+		wins INT NOT NULL DEFAULT 0,
+		losses INT NOT NULL DEFAULT 0,
+		draws INT NOT NULL DEFAULT 0,
+		last_played_at TIMESTAMPTZ NOT NULL
+	)`,
+}
+
+func OpenAnalyticsDB(dbURL string, sessions SessionConfig) (*AnalyticsDB, error) {
+	conn, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	a := &AnalyticsDB{conn: conn, sessions: sessions}
+	if err := a.InitDB(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *AnalyticsDB) InitDB() error {
+	for _, stmt := range schema {
+		if _, err := a.conn.Exec(stmt); err != nil {
+			return fmt.Errorf("creating analytics schema: %w", err)
+		}
+	}
+	log.Println("Analytics schema ready")
+	return nil
+}
+
+func (a *AnalyticsDB) Close() error {
+	return a.conn.Close()
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"eventschema"
+
+	"github.com/lib/pq"
+)
+
+// RetryConfig controls how failing messages are retried before they are
+// dead-lettered.
+type RetryConfig struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func LoadRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxRetries:     envInt("ANALYTICS_MAX_RETRIES", 5),
+		InitialBackoff: envDuration("ANALYTICS_RETRY_BACKOFF", 500*time.Millisecond),
+		MaxBackoff:     envDuration("ANALYTICS_RETRY_MAX_BACKOFF", 30*time.Second),
+	}
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// isTransient reports whether err comes from the database being unreachable,
+// overloaded or restarting, or from a conflict with another transaction,
+// rather than from the event itself. Retrying those succeeds eventually, so
+// they never dead-letter an event.
+func isTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08", // connection exception
+			"40", // transaction rollback: serialization failure, deadlock
+			"53", // insufficient resources
+			"57", // operator intervention: shutdown, cancelled query
+			"58": // system error
+			return true
+		}
+	}
+	return false
+}
+
+// backoff sleeps for exponentially longer between attempts, up to MaxBackoff.
+type backoff struct {
+	cfg  RetryConfig
+	next time.Duration
+}
+
+func newBackoff(cfg RetryConfig) *backoff {
+	return &backoff{cfg: cfg, next: cfg.InitialBackoff}
+}
+
+// wait sleeps for the current delay and doubles it. It returns false if ctx
+// was cancelled first.
+func (b *backoff) wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(b.next):
+	}
+	b.next *= 2
+	if b.next > b.cfg.MaxBackoff {
+		b.next = b.cfg.MaxBackoff
+	}
+	return true
+}
+
+func (b *backoff) reset() {
+	b.next = b.cfg.InitialBackoff
+}
+
+// DeadLetter is a message that couldn't be recorded.
+type DeadLetter struct {
+	ID         string
+	Position   Position
+	Payload    []byte
+	Reason     string
+	Attempts   int
+	FailedAt   time.Time
+	RedrivenAt *time.Time
+}
+
+func deadLetterID(pos Position) string {
+	return fmt.Sprintf("%s/%s/%d/%d", pos.Consumer, pos.Topic, pos.Partition, pos.Offset)
+}
+
+// DeadLetter stores the message at pos with the reason it failed and moves
+// the stored offset past it, in one transaction.
+func (a *AnalyticsDB) DeadLetter(pos Position, payload []byte, reason error, attempts int) error {
+	tx, err := a.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO analytics_dead_letters (id, consumer, topic, kafka_partition, kafka_offset, payload, reason, attempts, failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE SET
+			payload = EXCLUDED.payload,
+			reason = EXCLUDED.reason,
+			attempts = analytics_dead_letters.attempts + EXCLUDED.attempts,
+			failed_at = EXCLUDED.failed_at,
+			redriven_at = NULL
+	`, deadLetterID(pos), pos.Consumer, pos.Topic, pos.Partition, pos.Offset, payload, textColumn(reason.Error()), attempts, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return commitOffset(tx, pos)
+}
+
+// textColumn makes s storable in a TEXT column, which rejects NUL bytes and
+// invalid UTF-8. Decode errors can quote either from the message.
+func textColumn(s string) string {
+	return strings.ReplaceAll(strings.ToValidUTF8(s, "\uFFFD"), "\x00", "\uFFFD")
+}
+
+// ListDeadLetters returns up to limit dead letters, oldest first. Re-driven
+// ones are only included with all.
+func (a *AnalyticsDB) ListDeadLetters(limit int, all bool) ([]DeadLetter, error) {
+	rows, err := a.conn.Query(`
+		SELECT id, consumer, topic, kafka_partition, kafka_offset, payload, reason, attempts, failed_at, redriven_at
+		FROM analytics_dead_letters
+		WHERE $1 OR redriven_at IS NULL
+		ORDER BY failed_at, id
+		LIMIT $2
+	`, all, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := make([]DeadLetter, 0)
+	for rows.Next() {
+		var d DeadLetter
+		var redrivenAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.Position.Consumer, &d.Position.Topic, &d.Position.Partition, &d.Position.Offset,
+			&d.Payload, &d.Reason, &d.Attempts, &d.FailedAt, &redrivenAt); err != nil {
+			return nil, err
+		}
+		if redrivenAt.Valid {
+			d.RedrivenAt = &redrivenAt.Time
+		}
+		letters = append(letters, d)
+	}
+	return letters, rows.Err()
+}
+
+func (a *AnalyticsDB) GetDeadLetter(id string) (*DeadLetter, error) {
+	var d DeadLetter
+	var redrivenAt sql.NullTime
+	err := a.conn.QueryRow(`
+		SELECT id, consumer, topic, kafka_partition, kafka_offset, payload, reason, attempts, failed_at, redriven_at
+		FROM analytics_dead_letters WHERE id = $1
+	`, id).Scan(&d.ID, &d.Position.Consumer, &d.Position.Topic, &d.Position.Partition, &d.Position.Offset,
+		&d.Payload, &d.Reason, &d.Attempts, &d.FailedAt, &redrivenAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if redrivenAt.Valid {
+		d.RedrivenAt = &redrivenAt.Time
+	}
+	return &d, nil
+}
+
+// Redrive decodes and records a dead letter again, for example after a fix
+// or an upgrade that understands a newer schema version. The stored offsets
+// don't move. On failure the dead letter is kept with the new reason.
+func (a *AnalyticsDB) Redrive(d *DeadLetter) error {
+	err := a.redrive(d)
+	if err == nil {
+		return nil
+	}
+	if _, uerr := a.conn.Exec(`
+		UPDATE analytics_dead_letters SET reason = $1, attempts = attempts + 1, failed_at = $2
+		WHERE id = $3
+	`, textColumn(err.Error()), time.Now().UTC(), d.ID); uerr != nil {
+		log.Printf("Error updating dead letter %s: %v\n", d.ID, uerr)
+	}
+	return err
+}
+
+func (a *AnalyticsDB) redrive(d *DeadLetter) error {
+	event, err := eventschema.Decode(d.Payload)
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := a.recordEvent(tx, event, d.Position); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE analytics_dead_letters SET redriven_at = $1 WHERE id = $2`, time.Now().UTC(), d.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RunDeadLettersCommand implements:
+//
+//	analytics dead-letters list [--limit N] [--all]
+//	analytics dead-letters show <id>
+//	analytics dead-letters redrive <id>... | --all
+func RunDeadLettersCommand(db *AnalyticsDB, args []string) error {
+	usage := errors.New("usage: dead-letters list [--limit N] [--all] | show <id> | redrive <id>... | redrive --all")
+	if len(args) == 0 {
+		return usage
+	}
+
+	fs := flag.NewFlagSet("dead-letters "+args[0], flag.ContinueOnError)
+	limit := fs.Int("limit", 50, "most dead letters to list, or to redrive per batch with --all")
+	all := fs.Bool("all", false, "list: include re-driven ones; redrive: every pending one")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		letters, err := db.ListDeadLetters(*limit, *all)
+		if err != nil {
+			return err
+		}
+		for _, d := range letters {
+			status := "pending"
+			if d.RedrivenAt != nil {
+				status = "redriven " + d.RedrivenAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\tattempts=%d\t%s\t%s\n", d.ID, d.FailedAt.UTC().Format(time.RFC3339), d.Attempts, status, d.Reason)
+		}
+		fmt.Printf("%d dead letters\n", len(letters))
+		return nil
+
+	case "show":
+		if fs.NArg() != 1 {
+			return usage
+		}
+		d, err := db.GetDeadLetter(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			return fmt.Errorf("dead letter %q not found", fs.Arg(0))
+		}
+		// Binary payloads are shown quoted
+		payload := string(d.Payload)
+		if !utf8.Valid(d.Payload) || strings.ContainsRune(payload, 0) {
+			payload = strconv.Quote(payload)
+		}
+		fmt.Printf("ID:       %s\nFailed:   %s\nAttempts: %d\nReason:   %s\nPayload:  %s\n",
+			d.ID, d.FailedAt.UTC().Format(time.RFC3339), d.Attempts, d.Reason, payload)
+		return nil
+
+	case "redrive":
+		if *all {
+			return redriveAll(db, *limit)
+		}
+		if fs.NArg() == 0 {
+			return usage
+		}
+		var letters []DeadLetter
+		for _, id := range fs.Args() {
+			d, err := db.GetDeadLetter(id)
+			if err != nil {
+				return err
+			}
+			if d == nil {
+				return fmt.Errorf("dead letter %q not found", id)
+			}
+			letters = append(letters, *d)
+		}
+
+		failed := 0
+		for i := range letters {
+			if !redriveAndReport(db, &letters[i]) {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d dead letters failed again", failed, len(letters))
+		}
+		return nil
+
+	default:
+		return usage
+	}
+}
+
+// redriveAll redrives every pending dead letter, batchSize at a time. Letters
+// that fail again stay pending, so each is only tried once per run.
+func redriveAll(db *AnalyticsDB, batchSize int) error {
+	tried := make(map[string]bool)
+	failed := 0
+	for {
+		letters, err := db.ListDeadLetters(batchSize, false)
+		if err != nil {
+			return err
+		}
+
+		// Failures move to the back, as Redrive updates failed_at, so a
+		// batch of only tried letters means none are left
+		progressed := false
+		for i := range letters {
+			if tried[letters[i].ID] {
+				continue
+			}
+			tried[letters[i].ID] = true
+			progressed = true
+			if !redriveAndReport(db, &letters[i]) {
+				failed++
+			}
+		}
+
+		if !progressed || len(letters) < batchSize {
+			break
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d dead letters failed again", failed, len(tried))
+	}
+	fmt.Printf("%d dead letters redriven\n", len(tried))
+	return nil
+}
+
+// redriveAndReport redrives d and prints the outcome. It reports whether it
+// succeeded.
+func redriveAndReport(db *AnalyticsDB, d *DeadLetter) bool {
+	if err := db.Redrive(d); err != nil {
+		fmt.Printf("%s\tfailed: %v\n", d.ID, err)
+		return false
+	}
+	fmt.Printf("%s\tredriven\n", d.ID)
+	return true
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad connection", driver.ErrBadConn, true},
+		{"wrapped bad connection", fmt.Errorf("recording: %w", driver.ErrBadConn), true},
+		{"connection closed", io.ErrUnexpectedEOF, true},
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"connection failure", &pq.Error{Code: "08006"}, true},
+		{"serialization failure", &pq.Error{Code: "40001"}, true},
+		{"deadlock", &pq.Error{Code: "40P01"}, true},
+		{"too many connections", &pq.Error{Code: "53300"}, true},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, true},
+		{"invalid text", &pq.Error{Code: "22P02"}, false},
+		{"value too long", &pq.Error{Code: "22001"}, false},
+		{"not null violation", &pq.Error{Code: "23502"}, false},
+		{"invalid event", fmt.Errorf("%w: no eventId", errInvalidEvent), false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.err); got != tt.want {
+				t.Fatalf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDeadLetterBinaryPayload stores a message that is neither JSON nor
+// UTF-8, the poison message dead letters exist for, and reads it back intact.
+func TestDeadLetterBinaryPayload(t *testing.T) {
+	a := openTestDB(t)
+
+	pos := Position{Consumer: fmt.Sprint("test-", time.Now().UnixNano()), Topic: "game-events", Offset: 7}
+	payload := []byte("{\"eventId\":\"e1\x00\"}\xff\xfe")
+	if err := a.DeadLetter(pos, payload, fmt.Errorf("bad message %q\x00\xff", payload), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := a.GetDeadLetter(deadLetterID(pos))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d == nil {
+		t.Fatal("dead letter not stored")
+	}
+	if !bytes.Equal(d.Payload, payload) {
+		t.Fatalf("payload = %q, want %q", d.Payload, payload)
+	}
+	next, err := a.NextOffset(pos.Consumer, pos.Topic, pos.Partition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != pos.Offset+1 {
+		t.Fatalf("next offset = %d, want %d", next, pos.Offset+1)
+	}
+}
+
+func TestTextColumn(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"nul\x00byte", "nul�byte"},
+		{"bad \xff utf-8", "bad � utf-8"},
+		{"ünïcödé", "ünïcödé"},
+	}
+	for _, tt := range tests {
+		if got := textColumn(tt.in); got != tt.want {
+			t.Errorf("textColumn(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
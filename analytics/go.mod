@@ -3,11 +3,16 @@ module analytics
 go 1.21
 
 require (
-	github.com/segmentio/kafka-go v0.4.46
-	github.com/lib/pq v1.10.9
+	eventschema v0.0.0
 	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.9
+	github.com/segmentio/kafka-go v0.4.46
 )
 
 require (
+	github.com/google/uuid v1.5.0 // indirect
 	github.com/klauspost/compress v1.17.4 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
 )
+
+replace eventschema => ../eventschema
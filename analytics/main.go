@@ -2,33 +2,23 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"eventschema"
+
 	"github.com/joho/godotenv"
 	"github.com/segmentio/kafka-go"
 )
 
-type GameEvent struct {
-	EventType   string    `json:"eventType"`
-	GameID      string    `json:"gameId"`
-	Player      string    `json:"player"`
-	Opponent    string    `json:"opponent"`
-	Action      string    `json:"action"`
-	Column      int       `json:"column,omitempty"`
-	Row         int       `json:"row,omitempty"`
-	Timestamp   time.Time `json:"timestamp"`
-	IsBot       bool      `json:"isBot"`
-	GameResult  string    `json:"gameResult,omitempty"`
-	Duration    int       `json:"duration,omitempty"`
-}
-
-type AnalyticsDB struct {
-	dbURL string
-}
+// GameEvent is the schema shared with the backend; older versions are
+// upcast on decode.
+type GameEvent = eventschema.Event
 
 func main() {
 	godotenv.Load()
@@ -53,71 +43,247 @@ func main() {
 		log.Fatal("DATABASE_URL not set")
 	}
 
-	analyticsDB := &AnalyticsDB{dbURL: dbURL}
-
-	// Initialize database
-	if err := analyticsDB.InitDB(); err != nil {
+	analyticsDB, err := OpenAnalyticsDB(dbURL, LoadSessionConfig())
+	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
+	defer analyticsDB.Close()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "rebuild":
+			if err := analyticsDB.RebuildAggregates(); err != nil {
+				log.Fatal("Failed to rebuild aggregates:", err)
+			}
+			log.Println("Aggregates rebuilt")
+			return
+		case "dead-letters":
+			if err := RunDeadLettersCommand(analyticsDB, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "replay":
+			if err := RunReplayCommand(analyticsDB, kafkaGroup, kafkaTopic, os.Args[2:]); err != nil {
+				log.Fatal("Replay failed:", err)
+			}
+			return
+		case "delete-player":
+			if err := RunDeletePlayerCommand(analyticsDB, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8081"
+	}
+	api := NewAPIServer(analyticsDB, port)
+	go func() {
+		if err := api.Start(); err != nil {
+			log.Fatal("Analytics API failed:", err)
+		}
+	}()
 
 	log.Printf("Analytics service starting - Kafka: %s, Topic: %s, Group: %s\n", kafkaBroker, kafkaTopic, kafkaGroup)
 
 	// Start consuming events
-	if err := analyticsDB.ConsumeEvents(kafkaBroker, kafkaTopic, kafkaGroup); err != nil {
+	if err := analyticsDB.ConsumeEvents(ctx, kafkaBroker, kafkaTopic, kafkaGroup, LoadRetryConfig()); err != nil && ctx.Err() == nil {
 		log.Fatal("Failed to consume events:", err)
 	}
+
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := api.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down API: %v\n", err)
+	}
 }
 
-func (a *AnalyticsDB) InitDB() error {
-	// We'll store analytics in the same database
-	// Just validate connection
-	return nil
+// ConsumeEvents reads every partition of topic from the offsets stored for
+// group until ctx is cancelled. Offsets are kept in the database rather than
+// committed to Kafka, so one consumer per group should run at a time.
+func (a *AnalyticsDB) ConsumeEvents(ctx context.Context, broker string, topic string, group string, retry RetryConfig) error {
+	partitions, err := readPartitions(ctx, broker, topic)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Connected to Kafka topic: %s (%d partitions)\n", topic, len(partitions))
+
+	// One failing partition stops the others so the error is reported
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(partitions))
+	for _, partition := range partitions {
+		wg.Add(1)
+		go func(partition int) {
+			defer wg.Done()
+			if err := a.consumePartition(ctx, broker, Position{Consumer: group, Topic: topic, Partition: partition}, retry); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(partition)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// readPartitions lists topic's partitions, waiting for the broker and the
+// topic to exist.
+func readPartitions(ctx context.Context, broker string, topic string) ([]int, error) {
+	for {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err == nil {
+			var partitions []kafka.Partition
+			partitions, err = conn.ReadPartitions(topic)
+			conn.Close()
+			if err == nil && len(partitions) > 0 {
+				ids := make([]int, len(partitions))
+				for i, p := range partitions {
+					ids[i] = p.ID
+				}
+				return ids, nil
+			}
+		}
+
+		log.Printf("Waiting for Kafka topic %s: %v\n", topic, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
 }
 
-func (a *AnalyticsDB) ConsumeEvents(broker string, topic string, group string) error {
+// consumePartition processes one partition, starting after the last message
+// recorded for it. A message that can't be decoded, or still fails after
+// retry.MaxRetries attempts, is dead-lettered so the partition keeps moving.
+func (a *AnalyticsDB) consumePartition(ctx context.Context, broker string, pos Position, retry RetryConfig) error {
+	next, err := a.NextOffset(pos.Consumer, pos.Topic, pos.Partition)
+	if err != nil {
+		return err
+	}
+
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:        []string{broker},
-		Topic:          topic,
-		GroupID:        group,
-		StartOffset:    kafka.LastOffset,
-		CommitInterval: 1 * time.Second,
+		Brokers:   []string{broker},
+		Topic:     pos.Topic,
+		Partition: pos.Partition,
 	})
-
 	defer reader.Close()
 
-	log.Printf("Connected to Kafka topic: %s\n", topic)
-
-	ctx := context.Background()
+	if err := reader.SetOffset(next); err != nil {
+		return err
+	}
+	log.Printf("Reading partition %d from offset %d\n", pos.Partition, next)
 
+	readBackoff := newBackoff(retry)
 	for {
 		msg, err := reader.ReadMessage(ctx)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
 			log.Printf("Error reading message: %v\n", err)
+			if !readBackoff.wait(ctx) {
+				return nil
+			}
 			continue
 		}
+		readBackoff.reset()
+		pos.Offset = msg.Offset
 
-		var event GameEvent
-		if err := json.Unmarshal(msg.Value, &event); err != nil {
-			log.Printf("Error unmarshaling event: %v\n", err)
+		event, err := eventschema.Decode(msg.Value)
+		if err != nil {
+			log.Printf("Dead-lettering undecodable message at %d/%d: %v\n", pos.Partition, pos.Offset, err)
+			if !a.deadLetter(ctx, pos, msg.Value, err, 1, retry) {
+				return nil
+			}
 			continue
 		}
 
 		log.Printf("Event received: %s - Game: %s, Player: %s\n", event.EventType, event.GameID, event.Player)
 
-		// Process event
-		a.ProcessEvent(event)
+		// Only failures caused by the event count towards MaxRetries; while
+		// the database is unreachable the partition waits for it
+		b := newBackoff(retry)
+		failures := 0
+		for attempt := 1; ; attempt++ {
+			err := a.ProcessEvent(event, pos)
+			if err == nil {
+				break
+			}
+			if !isTransient(err) {
+				failures++
+			}
+			if errors.Is(err, errInvalidEvent) || failures > retry.MaxRetries {
+				log.Printf("Dead-lettering event %s after %d attempts: %v\n", event.EventID, attempt, err)
+				if !a.deadLetter(ctx, pos, msg.Value, err, attempt, retry) {
+					return nil
+				}
+				break
+			}
+			log.Printf("Error recording event %s (attempt %d): %v\n", event.EventID, attempt, err)
+			if !b.wait(ctx) {
+				return nil
+			}
+		}
+	}
+}
+
+// deadLetter stores a failed message, retrying while the database is
+// unavailable: moving on without it would lose the message. A message the
+// database refuses outright is logged and skipped rather than stalling the
+// partition. It returns false if ctx was cancelled.
+func (a *AnalyticsDB) deadLetter(ctx context.Context, pos Position, payload []byte, reason error, attempts int, retry RetryConfig) bool {
+	b := newBackoff(retry)
+	for {
+		err := a.DeadLetter(pos, payload, reason, attempts)
+		if err == nil {
+			return true
+		}
+		if !isTransient(err) {
+			log.Printf("Skipping message at %d/%d, which can't be dead-lettered: %v (payload %q)\n", pos.Partition, pos.Offset, err, payload)
+			return true
+		}
+		log.Printf("Error dead-lettering message at %d/%d: %v\n", pos.Partition, pos.Offset, err)
+		if !b.wait(ctx) {
+			return false
+		}
 	}
 }
 
-func (a *AnalyticsDB) ProcessEvent(event GameEvent) {
+// ProcessEvent records event, read from pos, then logs it. Events seen
+// before are skipped.
+func (a *AnalyticsDB) ProcessEvent(event GameEvent, pos Position) error {
+	recorded, err := a.RecordEvent(event, pos)
+	if err != nil {
+		return err
+	}
+	if !recorded {
+		log.Printf("Skipping duplicate event %s\n", event.EventID)
+		return nil
+	}
+
 	switch event.EventType {
-	case "game_completed":
+	case eventschema.GameCompleted:
 		a.ProcessGameCompletion(event)
-	case "game_move":
+	case eventschema.GameMove:
 		a.ProcessGameMove(event)
+	case eventschema.MatchmakingJoined, eventschema.MatchmakingLeft, eventschema.BotFallback,
+		eventschema.GameStarted, eventschema.PlayerDisconnected, eventschema.PlayerReconnected:
+		// Only kept in analytics_events
 	default:
 		log.Printf("Unknown event type: %s\n", event.EventType)
 	}
+	return nil
 }
 
 func (a *AnalyticsDB) ProcessGameCompletion(event GameEvent) {
@@ -131,38 +297,9 @@ func (a *AnalyticsDB) ProcessGameCompletion(event GameEvent) {
 	if event.IsBot {
 		log.Printf("  Game was vs Bot\n")
 	}
-
-	// In production, you would:
-	// 1. Store metrics in database
-	// 2. Calculate aggregates (average game duration, most frequent winners, etc.)
-	// 3. Track games per hour/day
-	// 4. Track user-specific metrics
-	a.LogGameMetrics(event)
 }
 
 func (a *AnalyticsDB) ProcessGameMove(event GameEvent) {
 	log.Printf("Game move - ID: %s, Player: %s, Column: %d, Row: %d\n",
 		event.GameID, event.Player, event.Column, event.Row)
 }
-
-func (a *AnalyticsDB) LogGameMetrics(event GameEvent) {
-	now := time.Now()
-	
-	metrics := map[string]interface{}{
-		"timestamp":     now.Format(time.RFC3339),
-		"game_id":       event.GameID,
-		"winner":        event.GameResult,
-		"player":        event.Player,
-		"opponent":      event.Opponent,
-		"duration_sec":  event.Duration,
-		"is_bot":        event.IsBot,
-		"hour_of_day":   now.Hour(),
-		"day_of_week":   now.Weekday(),
-		"date":          now.Format("2006-01-02"),
-	}
-
-	fmt.Printf("ANALYTICS: %+v\n", metrics)
-
-	// In production, save to analytics database
-	// For now, just logging
-}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"eventschema"
+)
+
+// Granularities of analytics_game_stats.
+const (
+	granularityHour = "hour"
+	granularityDay  = "day"
+)
+
+// errInvalidEvent marks events that can never be recorded, so retrying them
+// is pointless.
+var errInvalidEvent = errors.New("invalid event")
+
+// RecordEvent stores event, read from pos, and updates the aggregates it
+// affects and the stored offset, all in one transaction. It reports false if
+// the event was already recorded; only the offset moves then.
+func (a *AnalyticsDB) RecordEvent(event GameEvent, pos Position) (bool, error) {
+	tx, err := a.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	recorded, err := a.recordEvent(tx, event, pos)
+	if err != nil {
+		return false, err
+	}
+	return recorded, commitOffset(tx, pos)
+}
+
+func (a *AnalyticsDB) recordEvent(tx *sql.Tx, event GameEvent, pos Position) (bool, error) {
+	if event.EventID == "" {
+		return false, fmt.Errorf("%w: no eventId", errInvalidEvent)
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO analytics_events (event_id, event_type, game_id, player, occurred_at, recorded_at, kafka_partition, kafka_offset)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (event_id) DO NOTHING
+	`, event.EventID, event.EventType, event.GameID, event.Player, event.Timestamp.UTC(), time.Now().UTC(), pos.Partition, pos.Offset)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	watermark, err := a.watermark(tx)
+	if err != nil {
+		return false, err
+	}
+
+	switch event.EventType {
+	case eventschema.GameCompleted:
+		err = a.recordGame(tx, event, watermark)
+	case eventschema.GameMove:
+		err = recordMove(tx, event)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, a.closeSessions(tx, watermark)
+}
+
+// commitOffset stores the offset after pos and commits tx.
+func commitOffset(tx *sql.Tx, pos Position) error {
+	if err := saveOffset(tx, pos); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordGame adds a finished game to analytics_games, the aggregates and its
+// players' sessions. A game already recorded under another event ID is left
+// alone.
+func (a *AnalyticsDB) recordGame(tx *sql.Tx, event GameEvent, watermark time.Time) error {
+	completedAt := event.Timestamp.UTC()
+	hour := truncate(completedAt, granularityHour)
+	day := truncate(completedAt, granularityDay)
+	startedAt := completedAt.Add(-time.Duration(event.Duration) * time.Second)
+	late := isLate(startedAt, watermark, a.sessions.MaxGameDuration)
+
+	res, err := tx.Exec(`
+		INSERT INTO analytics_games (game_id, first_mover, second_mover, winner, is_bot, duration_sec, completed_at, completed_hour, completed_day, end_reason, late)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (game_id) DO NOTHING
+	`, event.GameID, event.Player, event.Opponent, winnerOf(event), event.IsBot, event.Duration, completedAt, hour, day, event.Reason, late)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	// Always hour then day, so concurrent transactions lock the rows in the
+	// same order
+	buckets := []struct {
+		granularity string
+		bucket      time.Time
+	}{
+		{granularityHour, hour},
+		{granularityDay, day},
+	}
+	for _, b := range buckets {
+		if err := addGameStats(tx, b.granularity, b.bucket, event); err != nil {
+			return err
+		}
+	}
+
+	for _, username := range humanPlayers(event.Player, event.Opponent, event.IsBot) {
+		if err := addPlayerActivity(tx, username, event); err != nil {
+			return err
+		}
+	}
+
+	if late {
+		log.Printf("Game %s started too far behind the watermark; left out of sessions\n", event.GameID)
+		return nil
+	}
+	for _, username := range humanPlayers(event.Player, event.Opponent, event.IsBot) {
+		if err := lockPlayerSessions(tx, username); err != nil {
+			return err
+		}
+		if err := a.addToSession(tx, username, event.GameID, startedAt, completedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// humanPlayers returns the players of a game that aren't the bot, sorted so
+// that transactions touching both players' rows lock them in the same order.
+func humanPlayers(firstMover, secondMover string, isBot bool) []string {
+	if isBot {
+		return []string{firstMover}
+	}
+	if secondMover < firstMover {
+		return []string{secondMover, firstMover}
+	}
+	return []string{firstMover, secondMover}
+}
+
+func addGameStats(tx *sql.Tx, granularity string, bucket time.Time, event GameEvent) error {
+	winner := winnerOf(event)
+	_, err := tx.Exec(`
+		INSERT INTO analytics_game_stats (granularity, bucket, games, bot_games, draws, first_mover_wins, total_duration_sec)
+		VALUES ($1, $2, 1, $3, $4, $5, $6)
+		ON CONFLICT (granularity, bucket) DO UPDATE SET
+			games = analytics_game_stats.games + 1,
+			bot_games = analytics_game_stats.bot_games + EXCLUDED.bot_games,
+			draws = analytics_game_stats.draws + EXCLUDED.draws,
+			first_mover_wins = analytics_game_stats.first_mover_wins + EXCLUDED.first_mover_wins,
+			total_duration_sec = analytics_game_stats.total_duration_sec + EXCLUDED.total_duration_sec
+	`, granularity, bucket, boolInt(event.IsBot), boolInt(winner == "draw"), boolInt(winner == event.Player), event.Duration)
+	return err
+}
+
+func addPlayerActivity(tx *sql.Tx, username string, event GameEvent) error {
+	winner := winnerOf(event)
+	win := winner == username
+	draw := winner == "draw"
+	_, err := tx.Exec(`
+		INSERT INTO analytics_player_activity (username, games, wins, losses, draws, last_played_at)
+		VALUES ($1, 1, $2, $3, $4, $5)
+		ON CONFLICT (username) DO UPDATE SET
+			games = analytics_player_activity.games + 1,
+			wins = analytics_player_activity.wins + EXCLUDED.wins,
+			losses = analytics_player_activity.losses + EXCLUDED.losses,
+			draws = analytics_player_activity.draws + EXCLUDED.draws,
+			last_played_at = CASE WHEN EXCLUDED.last_played_at > analytics_player_activity.last_played_at
+				THEN EXCLUDED.last_played_at ELSE analytics_player_activity.last_played_at END
+	`, username, boolInt(win), boolInt(!win && !draw), boolInt(draw), event.Timestamp.UTC())
+	return err
+}
+
+func recordMove(tx *sql.Tx, event GameEvent) error {
+	_, err := tx.Exec(`
+		INSERT INTO analytics_moves (event_id, game_id, move_number, player, col, row_index, played_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (event_id) DO NOTHING
+	`, event.EventID, event.GameID, event.MoveNumber, event.Player, event.Column, event.Row, event.Timestamp.UTC())
+	return err
+}
+
+// RebuildAggregates recomputes analytics_game_stats,
+// analytics_player_activity and analytics_sessions from analytics_games.
+func (a *AnalyticsDB) RebuildAggregates() error {
+	tx, err := a.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := a.rebuildAggregates(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (a *AnalyticsDB) rebuildAggregates(tx *sql.Tx) error {
+	stmts := []string{
+		`DELETE FROM analytics_game_stats`,
+		`DELETE FROM analytics_player_activity`,
+	}
+	columns := []struct{ granularity, column string }{
+		{granularityHour, "completed_hour"},
+		{granularityDay, "completed_day"},
+	}
+	for _, c := range columns {
+		granularity, column := c.granularity, c.column
+		stmts = append(stmts, `
+			INSERT INTO analytics_game_stats (granularity, bucket, games, bot_games, draws, first_mover_wins, total_duration_sec)
+			SELECT '`+granularity+`', `+column+`, COUNT(*),
+				SUM(CASE WHEN is_bot THEN 1 ELSE 0 END),
+				SUM(CASE WHEN winner = 'draw' THEN 1 ELSE 0 END),
+				SUM(CASE WHEN winner = first_mover THEN 1 ELSE 0 END),
+				SUM(duration_sec)
+			FROM analytics_games
+			GROUP BY `+column)
+	}
+	stmts = append(stmts, `
+		INSERT INTO analytics_player_activity (username, games, wins, losses, draws, last_played_at)
+		SELECT username, COUNT(*),
+			SUM(CASE WHEN winner = username THEN 1 ELSE 0 END),
+			SUM(CASE WHEN winner <> username AND winner <> 'draw' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN winner = 'draw' THEN 1 ELSE 0 END),
+			MAX(completed_at)
+		FROM (
+			SELECT first_mover AS username, winner, completed_at FROM analytics_games
+			UNION ALL
+			SELECT second_mover, winner, completed_at FROM analytics_games WHERE NOT is_bot
+		) p
+		GROUP BY username`)
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return a.rebuildSessions(tx)
+}
+
+// winnerOf returns the game's winner as stored in analytics_games. The
+// backend always sets one; a missing result is counted as a draw.
+func winnerOf(event GameEvent) string {
+	if event.GameResult == "" {
+		return "draw"
+	}
+	return event.GameResult
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"eventschema"
+)
+
+func TestWinnerOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		gameResult string
+		want       string
+	}{
+		{"winner", "alice", "alice"},
+		{"draw", "draw", "draw"},
+		{"missing result", "", "draw"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := winnerOf(GameEvent{GameResult: tt.gameResult})
+			if got != tt.want {
+				t.Fatalf("winnerOf(%q) = %q, want %q", tt.gameResult, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHumanPlayers(t *testing.T) {
+	tests := []struct {
+		name                    string
+		firstMover, secondMover string
+		isBot                   bool
+		want                    []string
+	}{
+		{"two humans", "alice", "bob", false, []string{"alice", "bob"}},
+		{"two humans, sorted", "bob", "alice", false, []string{"alice", "bob"}},
+		{"bot game", "bob", "bot", true, []string{"bob"}},
+		{"bot game, bot sorts first", "zoe", "Bot", true, []string{"zoe"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := humanPlayers(tt.firstMover, tt.secondMover, tt.isBot)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("humanPlayers(%q, %q, %v) = %v, want %v", tt.firstMover, tt.secondMover, tt.isBot, got, tt.want)
+			}
+		})
+	}
+}
+
+// openTestDB connects to the database in ANALYTICS_TEST_DATABASE_URL, or
+// skips the test if it isn't set. Tests use unique names so they can share
+// the database.
+func openTestDB(t *testing.T) *AnalyticsDB {
+	t.Helper()
+	dbURL := os.Getenv("ANALYTICS_TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("ANALYTICS_TEST_DATABASE_URL not set")
+	}
+	a, err := OpenAnalyticsDB(dbURL, LoadSessionConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+// TestRecordEventIdempotent delivers a finished game three times: the same
+// event twice, then again under a new event ID as an outbox redelivery from
+// an older release would. The game and its aggregates count once.
+func TestRecordEventIdempotent(t *testing.T) {
+	a := openTestDB(t)
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	alice, bob := "alice-"+suffix, "bob-"+suffix
+	completedAt := time.Now().UTC().Truncate(time.Second)
+	event := GameEvent{
+		EventID:    "game-" + suffix + "/completed",
+		EventType:  eventschema.GameCompleted,
+		GameID:     "game-" + suffix,
+		Player:     alice,
+		Opponent:   bob,
+		GameResult: alice,
+		Duration:   90,
+		Timestamp:  completedAt,
+	}
+	pos := Position{Consumer: "test-" + suffix, Topic: "game-events"}
+
+	hourGames := func() int {
+		var games int
+		err := a.conn.QueryRow(`
+			SELECT COALESCE(SUM(games), 0) FROM analytics_game_stats WHERE granularity = $1 AND bucket = $2
+		`, granularityHour, truncate(completedAt, granularityHour)).Scan(&games)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return games
+	}
+	before := hourGames()
+
+	redelivered := event
+	redelivered.EventID = "redelivered-" + suffix
+	for i, e := range []GameEvent{event, event, redelivered} {
+		pos.Offset = int64(i)
+		recorded, err := a.RecordEvent(e, pos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i != 1; recorded != want {
+			t.Fatalf("delivery %d: recorded = %v, want %v", i, recorded, want)
+		}
+	}
+
+	if got := hourGames() - before; got != 1 {
+		t.Fatalf("hourly games grew by %d, want 1", got)
+	}
+	for _, username := range []string{alice, bob} {
+		var games, wins int
+		err := a.conn.QueryRow(`
+			SELECT games, wins FROM analytics_player_activity WHERE username = $1
+		`, username).Scan(&games, &wins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := boolInt(username == alice); games != 1 || wins != want {
+			t.Fatalf("%s: games = %d, wins = %d, want 1 and %d", username, games, wins, want)
+		}
+	}
+
+	next, err := a.NextOffset(pos.Consumer, pos.Topic, pos.Partition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 3 {
+		t.Fatalf("next offset = %d, want 3", next)
+	}
+}
+
+// TestRewindReplay records games from two partitions, rewinds one of them and
+// reads its events again. The other partition is left alone, and once the
+// events are read again the facts and aggregates are as they were.
+func TestRewindReplay(t *testing.T) {
+	a := openTestDB(t)
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	alice, bob, carol := "alice-"+suffix, "bob-"+suffix, "carol-"+suffix
+	completedAt := time.Now().UTC().Truncate(time.Second)
+	game := func(id, player, opponent, winner string) GameEvent {
+		return GameEvent{
+			EventID:    id + "-" + suffix + "/completed",
+			EventType:  eventschema.GameCompleted,
+			GameID:     id + "-" + suffix,
+			Player:     player,
+			Opponent:   opponent,
+			GameResult: winner,
+			Duration:   60,
+			Timestamp:  completedAt,
+		}
+	}
+	consumer, topic := "test-"+suffix, "game-events"
+	deliveries := []struct {
+		event     GameEvent
+		partition int
+		offset    int64
+	}{
+		{game("g1", alice, bob, alice), 0, 0},
+		{game("g2", bob, alice, bob), 0, 1},
+		{game("g3", alice, carol, "draw"), 0, 2},
+		{game("g4", carol, bob, carol), 1, 1},
+	}
+	deliver := func(partition int, from int64) {
+		t.Helper()
+		for _, d := range deliveries {
+			if d.partition != partition || d.offset < from {
+				continue
+			}
+			pos := Position{Consumer: consumer, Topic: topic, Partition: d.partition, Offset: d.offset}
+			if recorded, err := a.RecordEvent(d.event, pos); err != nil || !recorded {
+				t.Fatalf("recording %s: recorded = %v, err = %v", d.event.EventID, recorded, err)
+			}
+		}
+	}
+	snapshot := func() string {
+		t.Helper()
+		var out []string
+		var games, draws, duration int
+		err := a.conn.QueryRow(`
+			SELECT games, draws, total_duration_sec FROM analytics_game_stats WHERE granularity = $1 AND bucket = $2
+		`, granularityHour, truncate(completedAt, granularityHour)).Scan(&games, &draws, &duration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, fmt.Sprintf("hour %d/%d/%d", games, draws, duration))
+		for _, username := range []string{alice, bob, carol} {
+			var games, wins, losses, draws int
+			err := a.conn.QueryRow(`
+				SELECT games, wins, losses, draws FROM analytics_player_activity WHERE username = $1
+			`, username).Scan(&games, &wins, &losses, &draws)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, fmt.Sprintf("%s %d/%d/%d/%d", strings.TrimSuffix(username, "-"+suffix), games, wins, losses, draws))
+		}
+		for _, partition := range []int{0, 1} {
+			next, err := a.NextOffset(consumer, topic, partition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, fmt.Sprintf("p%d@%d", partition, next))
+		}
+		return strings.Join(out, " ")
+	}
+
+	deliver(0, 0)
+	deliver(1, 0)
+	before := snapshot()
+
+	forgotten, err := a.Rewind(consumer, topic, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forgotten != 2 {
+		t.Fatalf("forgot %d events, want 2", forgotten)
+	}
+	rewound := snapshot()
+	for _, want := range []string{"alice 1/1/0/0", "bob 2/0/2/0", "carol 1/1/0/0", "p0@1", "p1@2"} {
+		if !strings.Contains(rewound, want) {
+			t.Fatalf("after rewinding: %s, want %s", rewound, want)
+		}
+	}
+
+	deliver(0, 1)
+	if after := snapshot(); after != before {
+		t.Fatalf("after replaying: %s, want %s", after, before)
+	}
+
+	if _, err := a.Rewind(consumer, topic, -1, 0); err == nil {
+		t.Fatal("rewinding every partition at once succeeded")
+	}
+}
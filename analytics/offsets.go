@@ -0,0 +1,121 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Position is where a message was read from. Consumer is the KAFKA_GROUP
+// name, so several consumers of one topic keep separate offsets.
+type Position struct {
+	Consumer  string
+	Topic     string
+	Partition int
+	Offset    int64
+}
+
+// saveOffset records that everything up to and including pos was processed.
+func saveOffset(tx *sql.Tx, pos Position) error {
+	_, err := tx.Exec(`
+		INSERT INTO analytics_offsets (consumer, topic, kafka_partition, next_offset, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (consumer, topic, kafka_partition) DO UPDATE SET
+			next_offset = EXCLUDED.next_offset,
+			updated_at = EXCLUDED.updated_at
+	`, pos.Consumer, pos.Topic, pos.Partition, pos.Offset+1, time.Now().UTC())
+	return err
+}
+
+// NextOffset returns the offset to resume partition from, or
+// kafka.FirstOffset if nothing was read from it yet.
+func (a *AnalyticsDB) NextOffset(consumer, topic string, partition int) (int64, error) {
+	var next int64
+	err := a.conn.QueryRow(`
+		SELECT next_offset FROM analytics_offsets
+		WHERE consumer = $1 AND topic = $2 AND kafka_partition = $3
+	`, consumer, topic, partition).Scan(&next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return kafka.FirstOffset, nil
+	}
+	return next, err
+}
+
+// Rewind forgets every event read at or after offset from so that the
+// consumer reads them again: their facts are deleted, the stored offsets are
+// moved back and the aggregates rebuilt from the facts that remain. Offsets
+// are per partition, so only one partition is rewound at a time. Events
+// recorded before offsets were stored are kept; reading them again is
+// harmless since they are skipped as duplicates. It returns how many events
+// were forgotten.
+func (a *AnalyticsDB) Rewind(consumer, topic string, partition int, from int64) (int64, error) {
+	if partition < 0 || from < 0 {
+		return 0, fmt.Errorf("invalid rewind of partition %d to offset %d", partition, from)
+	}
+
+	tx, err := a.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	replayed := `SELECT %s FROM analytics_events
+		WHERE kafka_offset >= $1 AND kafka_partition = $2%s`
+	stmts := []string{
+		`DELETE FROM analytics_games WHERE game_id IN (` + fmt.Sprintf(replayed, "game_id", ` AND event_type = 'game_completed'`) + `)`,
+		`DELETE FROM analytics_moves WHERE event_id IN (` + fmt.Sprintf(replayed, "event_id", "") + `)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt, from, partition); err != nil {
+			return 0, err
+		}
+	}
+
+	res, err := tx.Exec(`DELETE FROM analytics_events WHERE kafka_offset >= $1 AND kafka_partition = $2`, from, partition)
+	if err != nil {
+		return 0, err
+	}
+	forgotten, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE analytics_offsets SET next_offset = $1, updated_at = $2
+		WHERE consumer = $3 AND topic = $4 AND kafka_partition = $5 AND next_offset > $1
+	`, from, time.Now().UTC(), consumer, topic, partition); err != nil {
+		return 0, err
+	}
+
+	if err := a.rebuildAggregates(tx); err != nil {
+		return 0, err
+	}
+	return forgotten, tx.Commit()
+}
+
+// RunReplayCommand implements `analytics replay --partition N --from
+// <offset>`. An offset means nothing outside its partition, so --partition is
+// required. The consumer must be stopped first, or it will store its own
+// offsets over the rewound ones; it resumes from --from when started again.
+func RunReplayCommand(db *AnalyticsDB, consumer, topic string, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	from := fs.Int64("from", -1, "offset to replay from")
+	partition := fs.Int("partition", -1, "partition to replay")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from < 0 || *partition < 0 {
+		return errors.New("usage: replay --partition <N> --from <offset>")
+	}
+
+	forgotten, err := db.Rewind(consumer, topic, *partition, *from)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rewound %s partition %d to offset %d; %d events will be read again\n", topic, *partition, *from, forgotten)
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AnonymizePlayer replaces username with alias, the one the backend's
+// delete-player chose, in every recorded event, game, move, session and
+// activity row and in dead-lettered messages. Aggregates don't name players
+// and are unchanged. It reports whether anything named the player.
+func (a *AnalyticsDB) AnonymizePlayer(username, alias string) (bool, error) {
+	tx, err := a.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Keep consumers from adding games to the player's sessions meanwhile
+	if err := lockPlayerSessions(tx, username); err != nil {
+		return false, err
+	}
+
+	found := false
+	for _, stmt := range []string{
+		`UPDATE analytics_events SET player = $2 WHERE player = $1`,
+		`UPDATE analytics_games SET first_mover = $2 WHERE first_mover = $1`,
+		`UPDATE analytics_games SET second_mover = $2 WHERE second_mover = $1`,
+		`UPDATE analytics_games SET winner = $2 WHERE winner = $1`,
+		`UPDATE analytics_moves SET player = $2 WHERE player = $1`,
+		`UPDATE analytics_player_activity SET username = $2 WHERE username = $1`,
+		// Session IDs start with the username
+		`UPDATE analytics_sessions SET id = $2 || substr(id, length($1) + 1), username = $2 WHERE username = $1`,
+	} {
+		res, err := tx.Exec(stmt, username, alias)
+		if err != nil {
+			return false, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+		found = found || n > 0
+	}
+
+	renamed, err := anonymizeDeadLetters(tx, username, alias)
+	if err != nil {
+		return false, err
+	}
+	return found || renamed, tx.Commit()
+}
+
+// anonymizeDeadLetters rewrites the player's name wherever it appears as a
+// JSON string in a dead letter's payload or reason. Payloads may not be valid
+// JSON, so they are rewritten as bytes rather than decoded.
+func anonymizeDeadLetters(tx *sql.Tx, username, alias string) (bool, error) {
+	quoted, _ := json.Marshal(username)
+	quotedAlias, _ := json.Marshal(alias)
+
+	rows, err := tx.Query(`SELECT id, payload, reason FROM analytics_dead_letters`)
+	if err != nil {
+		return false, err
+	}
+	type letter struct {
+		id      string
+		payload []byte
+		reason  string
+	}
+	var matches []letter
+	for rows.Next() {
+		var d letter
+		if err := rows.Scan(&d.id, &d.payload, &d.reason); err != nil {
+			rows.Close()
+			return false, err
+		}
+		if bytes.Contains(d.payload, quoted) || strings.Contains(d.reason, string(quoted)) {
+			matches = append(matches, d)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	for _, d := range matches {
+		_, err := tx.Exec(`UPDATE analytics_dead_letters SET payload = $1, reason = $2 WHERE id = $3`,
+			bytes.ReplaceAll(d.payload, quoted, quotedAlias),
+			strings.ReplaceAll(d.reason, string(quoted), string(quotedAlias)),
+			d.id)
+		if err != nil {
+			return false, err
+		}
+	}
+	return len(matches) > 0, nil
+}
+
+// RunDeletePlayerCommand implements `analytics delete-player <username>
+// <alias>`, run with the alias `backend delete-player` printed.
+func RunDeletePlayerCommand(db *AnalyticsDB, args []string) error {
+	if len(args) != 2 || args[0] == "" || args[1] == "" {
+		return errors.New("usage: delete-player <username> <alias>")
+	}
+	found, err := db.AnonymizePlayer(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("player %q not found in the analytics database", args[0])
+	}
+	fmt.Printf("Replaced %s with %s in the analytics database\n", args[0], args[1])
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"eventschema"
+)
+
+// TestAnonymizePlayer records a game, one of its moves and a dead letter
+// naming alice, then checks nothing names her afterwards while bob's rows
+// are untouched.
+func TestAnonymizePlayer(t *testing.T) {
+	a := openTestDB(t)
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	alice, bob, alias := "alice-"+suffix, "bob-"+suffix, "deleted-"+suffix
+	now := time.Now().UTC().Truncate(time.Second)
+	pos := Position{Consumer: "test-" + suffix, Topic: "game-events"}
+	events := []GameEvent{
+		{EventID: "move-" + suffix, EventType: eventschema.GameMove, GameID: "game-" + suffix, Player: alice, Opponent: bob, MoveNumber: 1, Column: 3, Row: 5, Timestamp: now.Add(-time.Minute)},
+		{EventID: "completed-" + suffix, EventType: eventschema.GameCompleted, GameID: "game-" + suffix, Player: alice, Opponent: bob, GameResult: alice, Duration: 60, Timestamp: now},
+	}
+	for i, e := range events {
+		pos.Offset = int64(i)
+		if _, err := a.RecordEvent(e, pos); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payload := []byte(`{"eventType":"game_move","player":"` + alice + `","opponent":"` + bob + `"` + "\x00}")
+	pos.Offset = 2
+	if err := a.DeadLetter(pos, payload, errors.New(`bad event for "`+alice+`"`), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := a.AnonymizePlayer(alice, alias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("alice not found")
+	}
+
+	count := func(query string, username string) int {
+		t.Helper()
+		var n int
+		if err := a.conn.QueryRow(query, username).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	queries := []struct {
+		query            string
+		alice, bob, want int
+	}{
+		{`SELECT COUNT(*) FROM analytics_events WHERE player = $1`, 0, 0, 2},
+		{`SELECT COUNT(*) FROM analytics_games WHERE first_mover = $1 OR second_mover = $1 OR winner = $1`, 0, 1, 1},
+		{`SELECT COUNT(*) FROM analytics_moves WHERE player = $1`, 0, 0, 1},
+		{`SELECT COUNT(*) FROM analytics_player_activity WHERE username = $1`, 0, 1, 1},
+		{`SELECT COUNT(*) FROM analytics_sessions WHERE username = $1 OR id LIKE $1 || '/%'`, 0, 1, 1},
+	}
+	for _, q := range queries {
+		got := []int{count(q.query, alice), count(q.query, bob), count(q.query, alias)}
+		if want := []int{q.alice, q.bob, q.want}; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s: alice, bob and the alias = %v, want %v", q.query, got, want)
+		}
+	}
+
+	d, err := a.GetDeadLetter(deadLetterID(pos))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d == nil {
+		t.Fatal("dead letter gone")
+	}
+	want := bytes.ReplaceAll(payload, []byte(`"`+alice+`"`), []byte(`"`+alias+`"`))
+	if !bytes.Equal(d.Payload, want) || d.Reason != `bad event for "`+alias+`"` {
+		t.Fatalf("dead letter = %q (%s), want %q naming %s", d.Payload, d.Reason, want, alias)
+	}
+
+	if found, err := a.AnonymizePlayer(alice, "deleted-again"); err != nil || found {
+		t.Fatalf("anonymizing alice again: found = %v, %v; want false", found, err)
+	}
+}
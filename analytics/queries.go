@@ -0,0 +1,368 @@
+package main
+
+import (
+	"time"
+
+	"eventschema"
+)
+
+// boardColumns and boardRows match the backend's board.
+const (
+	boardColumns = 7
+	boardRows    = 6
+)
+
+// Range selects [From, To) at a granularity of granularityHour or
+// granularityDay.
+type Range struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+}
+
+// GameStats is one bucket of analytics_game_stats.
+type GameStats struct {
+	Bucket                 time.Time `json:"bucket"`
+	Games                  int       `json:"games"`
+	BotGames               int       `json:"botGames"`
+	Draws                  int       `json:"draws"`
+	FirstMoverWins         int       `json:"firstMoverWins"`
+	AverageDurationSeconds float64   `json:"averageDurationSeconds"`
+	BotShare               float64   `json:"botShare"`
+	FirstMoverWinRate      float64   `json:"firstMoverWinRate"`
+}
+
+// DurationBucket counts games lasting [MinSeconds, MaxSeconds).
+type DurationBucket struct {
+	MinSeconds int `json:"minSeconds"`
+	MaxSeconds int `json:"maxSeconds"`
+	Games      int `json:"games"`
+}
+
+// ActiveUsers counts distinct players who finished a game in a bucket.
+type ActiveUsers struct {
+	Bucket time.Time `json:"bucket"`
+	Users  int       `json:"users"`
+}
+
+// BotFallbacks compares matchmaking requests with those that ended in a bot
+// game because nobody else was waiting.
+type BotFallbacks struct {
+	Bucket    time.Time `json:"bucket"`
+	Requests  int       `json:"requests"`
+	Fallbacks int       `json:"fallbacks"`
+	Rate      float64   `json:"rate"`
+}
+
+// Heatmap counts moves by column, and by cell with row 0 at the top.
+type Heatmap struct {
+	Moves   int                          `json:"moves"`
+	Columns [boardColumns]int            `json:"columns"`
+	Cells   [boardRows][boardColumns]int `json:"cells"`
+}
+
+// SessionStats summarises the closed sessions that started in a bucket.
+type SessionStats struct {
+	Bucket                  time.Time `json:"bucket"`
+	Sessions                int       `json:"sessions"`
+	AverageLengthSeconds    float64   `json:"averageLengthSeconds"`
+	AverageGames            float64   `json:"averageGames"`
+	RageQuits               int       `json:"rageQuits"`
+	EndedOnRageQuit         int       `json:"endedOnRageQuit"`
+	EndedOnLossStreak       int       `json:"endedOnLossStreak"`
+	totalLength, totalGames int
+}
+
+// ChurnSignals counts players by what their most recent session suggests.
+// Players are active or inactive; one with an open session shows no signal.
+type ChurnSignals struct {
+	Watermark         time.Time `json:"watermark"`
+	Players           int       `json:"players"`
+	Active            int       `json:"active"`
+	Inactive          int       `json:"inactive"`
+	EndedOnRageQuit   int       `json:"endedOnRageQuit"`
+	EndedOnLossStreak int       `json:"endedOnLossStreak"`
+	AtRisk            int       `json:"atRisk"`
+}
+
+func (a *AnalyticsDB) GameStats(r Range) ([]GameStats, error) {
+	rows, err := a.conn.Query(`
+		SELECT bucket, games, bot_games, draws, first_mover_wins, total_duration_sec
+		FROM analytics_game_stats
+		WHERE granularity = $1 AND bucket >= $2 AND bucket < $3
+		ORDER BY bucket
+	`, r.Granularity, r.From, r.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make([]GameStats, 0)
+	for rows.Next() {
+		var s GameStats
+		var totalDuration int64
+		if err := rows.Scan(&s.Bucket, &s.Games, &s.BotGames, &s.Draws, &s.FirstMoverWins, &totalDuration); err != nil {
+			return nil, err
+		}
+		s.Bucket = s.Bucket.UTC()
+		s.AverageDurationSeconds = ratio(int(totalDuration), s.Games)
+		s.BotShare = ratio(s.BotGames, s.Games)
+		s.FirstMoverWinRate = ratio(s.FirstMoverWins, s.Games)
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// DurationHistogram buckets games finished in r by duration, width seconds
+// per bucket. Empty buckets are left out.
+func (a *AnalyticsDB) DurationHistogram(r Range, width int) ([]DurationBucket, error) {
+	rows, err := a.conn.Query(`
+		SELECT duration_sec / $1 AS b, COUNT(*)
+		FROM analytics_games
+		WHERE completed_at >= $2 AND completed_at < $3
+		GROUP BY b
+		ORDER BY b
+	`, width, r.From, r.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histogram := make([]DurationBucket, 0)
+	for rows.Next() {
+		var b, games int
+		if err := rows.Scan(&b, &games); err != nil {
+			return nil, err
+		}
+		histogram = append(histogram, DurationBucket{MinSeconds: b * width, MaxSeconds: (b + 1) * width, Games: games})
+	}
+	return histogram, rows.Err()
+}
+
+// ActiveUsers returns, per bucket, how many players finished a game, and how
+// many distinct players did over the whole range.
+func (a *AnalyticsDB) ActiveUsers(r Range) ([]ActiveUsers, int, error) {
+	column := "completed_day"
+	if r.Granularity == granularityHour {
+		column = "completed_hour"
+	}
+
+	players := `
+		SELECT ` + column + ` AS bucket, first_mover AS username FROM analytics_games
+		WHERE completed_at >= $1 AND completed_at < $2
+		UNION ALL
+		SELECT ` + column + `, second_mover FROM analytics_games
+		WHERE completed_at >= $1 AND completed_at < $2 AND NOT is_bot`
+
+	rows, err := a.conn.Query(`
+		SELECT bucket, COUNT(DISTINCT username) FROM (`+players+`) p
+		GROUP BY bucket
+		ORDER BY bucket
+	`, r.From, r.To)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	series := make([]ActiveUsers, 0)
+	for rows.Next() {
+		var u ActiveUsers
+		if err := rows.Scan(&u.Bucket, &u.Users); err != nil {
+			return nil, 0, err
+		}
+		u.Bucket = u.Bucket.UTC()
+		series = append(series, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	var total int
+	err = a.conn.QueryRow(`SELECT COUNT(DISTINCT username) FROM (`+players+`) p`, r.From, r.To).Scan(&total)
+	return series, total, err
+}
+
+// BotFallbacks returns the share of matchmaking requests that fell back to a
+// bot, per bucket.
+func (a *AnalyticsDB) BotFallbacks(r Range) ([]BotFallbacks, error) {
+	rows, err := a.conn.Query(`
+		SELECT event_type, occurred_at
+		FROM analytics_events
+		WHERE event_type IN ($1, $2) AND occurred_at >= $3 AND occurred_at < $4
+		ORDER BY occurred_at
+	`, eventschema.MatchmakingJoined, eventschema.BotFallback, r.From, r.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := make([]BotFallbacks, 0)
+	for rows.Next() {
+		var eventType string
+		var occurredAt time.Time
+		if err := rows.Scan(&eventType, &occurredAt); err != nil {
+			return nil, err
+		}
+
+		bucket := truncate(occurredAt, r.Granularity)
+		if len(series) == 0 || !series[len(series)-1].Bucket.Equal(bucket) {
+			series = append(series, BotFallbacks{Bucket: bucket})
+		}
+		b := &series[len(series)-1]
+		if eventType == eventschema.BotFallback {
+			b.Fallbacks++
+		} else {
+			b.Requests++
+		}
+	}
+	for i := range series {
+		series[i].Rate = ratio(series[i].Fallbacks, series[i].Requests)
+	}
+	return series, rows.Err()
+}
+
+// ColumnHeatmap counts the moves played in r.
+func (a *AnalyticsDB) ColumnHeatmap(r Range) (*Heatmap, error) {
+	rows, err := a.conn.Query(`
+		SELECT col, row_index, COUNT(*)
+		FROM analytics_moves
+		WHERE played_at >= $1 AND played_at < $2
+		GROUP BY col, row_index
+	`, r.From, r.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	heatmap := &Heatmap{}
+	for rows.Next() {
+		var col, row, moves int
+		if err := rows.Scan(&col, &row, &moves); err != nil {
+			return nil, err
+		}
+		if col < 0 || col >= boardColumns || row < 0 || row >= boardRows {
+			continue
+		}
+		heatmap.Moves += moves
+		heatmap.Columns[col] += moves
+		heatmap.Cells[row][col] += moves
+	}
+	return heatmap, rows.Err()
+}
+
+func (a *AnalyticsDB) SessionStats(r Range) ([]SessionStats, error) {
+	rows, err := a.conn.Query(`
+		SELECT started_at, ended_at, games, rage_quits, ended_on_rage_quit, final_loss_streak
+		FROM analytics_sessions
+		WHERE status = $1 AND started_at >= $2 AND started_at < $3
+		ORDER BY started_at
+	`, sessionClosed, r.From, r.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := make([]SessionStats, 0)
+	for rows.Next() {
+		var startedAt, endedAt time.Time
+		var games, rageQuits, lossStreak int
+		var endedOnRageQuit bool
+		if err := rows.Scan(&startedAt, &endedAt, &games, &rageQuits, &endedOnRageQuit, &lossStreak); err != nil {
+			return nil, err
+		}
+
+		bucket := truncate(startedAt, r.Granularity)
+		if len(series) == 0 || !series[len(series)-1].Bucket.Equal(bucket) {
+			series = append(series, SessionStats{Bucket: bucket})
+		}
+		s := &series[len(series)-1]
+		s.Sessions++
+		s.totalLength += int(endedAt.Sub(startedAt).Seconds())
+		s.totalGames += games
+		s.RageQuits += rageQuits
+		if endedOnRageQuit {
+			s.EndedOnRageQuit++
+		}
+		if lossStreak >= a.sessions.ChurnLossStreak {
+			s.EndedOnLossStreak++
+		}
+	}
+	for i := range series {
+		series[i].AverageLengthSeconds = ratio(series[i].totalLength, series[i].Sessions)
+		series[i].AverageGames = ratio(series[i].totalGames, series[i].Sessions)
+	}
+	return series, rows.Err()
+}
+
+// Churn evaluates every player's most recent session against the watermark.
+func (a *AnalyticsDB) Churn() (*ChurnSignals, error) {
+	watermark, err := a.watermark(a.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := a.conn.Query(`
+		SELECT username, status, ended_at, ended_on_rage_quit, final_loss_streak
+		FROM (
+			SELECT username, status, ended_at, ended_on_rage_quit, final_loss_streak,
+				ROW_NUMBER() OVER (PARTITION BY username ORDER BY ended_at DESC) AS n
+			FROM analytics_sessions
+		) latest
+		WHERE n = 1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	churn := &ChurnSignals{Watermark: watermark}
+	for rows.Next() {
+		var username, status string
+		var endedAt time.Time
+		var endedOnRageQuit bool
+		var lossStreak int
+		if err := rows.Scan(&username, &status, &endedAt, &endedOnRageQuit, &lossStreak); err != nil {
+			return nil, err
+		}
+
+		churn.Players++
+		if status == sessionOpen {
+			churn.Active++
+			continue
+		}
+		inactive := endedAt.Before(watermark.Add(-a.sessions.ChurnInactiveAfter))
+		lossStreakEnd := lossStreak >= a.sessions.ChurnLossStreak
+		if inactive {
+			churn.Inactive++
+		} else {
+			churn.Active++
+		}
+		if endedOnRageQuit {
+			churn.EndedOnRageQuit++
+		}
+		if lossStreakEnd {
+			churn.EndedOnLossStreak++
+		}
+		if inactive || endedOnRageQuit || lossStreakEnd {
+			churn.AtRisk++
+		}
+	}
+	return churn, rows.Err()
+}
+
+// truncate returns the start of the hour or UTC day containing t.
+func truncate(t time.Time, granularity string) time.Time {
+	t = t.UTC()
+	if granularity == granularityHour {
+		return t.Truncate(time.Hour)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
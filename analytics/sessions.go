@@ -0,0 +1,326 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	"eventschema"
+)
+
+const (
+	sessionOpen   = "open"
+	sessionClosed = "closed"
+)
+
+// SessionConfig controls how games are grouped into sessions and when a
+// player shows signs of churning.
+type SessionConfig struct {
+	Gap                time.Duration // longest break between games of one session
+	AllowedLateness    time.Duration // how far behind the newest event the watermark trails
+	MaxGameDuration    time.Duration // longer games may be left out of sessions
+	ChurnInactiveAfter time.Duration // no session for this long marks a player inactive
+	ChurnLossStreak    int           // losses in a row that end a session on a churn signal
+}
+
+func LoadSessionConfig() SessionConfig {
+	return SessionConfig{
+		Gap:                envDuration("SESSION_GAP", 30*time.Minute),
+		AllowedLateness:    envDuration("SESSION_ALLOWED_LATENESS", 5*time.Minute),
+		MaxGameDuration:    envDuration("SESSION_MAX_GAME_DURATION", time.Hour),
+		ChurnInactiveAfter: envDuration("CHURN_INACTIVE_AFTER", 14*24*time.Hour),
+		ChurnLossStreak:    envInt("CHURN_LOSS_STREAK", 3),
+	}
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// watermark is the event time up to which sessions are assumed complete: the
+// newest event seen, never ahead of the clock, less AllowedLateness. It is
+// zero before any event.
+func (a *AnalyticsDB) watermark(q querier) (time.Time, error) {
+	var newest time.Time
+	err := q.QueryRow(`SELECT occurred_at FROM analytics_events ORDER BY occurred_at DESC LIMIT 1`).Scan(&newest)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if now := time.Now(); newest.After(now) {
+		newest = now
+	}
+	return newest.UTC().Add(-a.sessions.AllowedLateness), nil
+}
+
+// isLate reports whether a game that started at startedAt may belong to a
+// session that is already closed. Sessions close once they ended more than
+// Gap plus maxGameDuration behind the watermark, so a game that started no
+// more than maxGameDuration behind it starts over a Gap after any of them.
+// Games arriving out of order and games longer than maxGameDuration can be
+// late.
+func isLate(startedAt, watermark time.Time, maxGameDuration time.Duration) bool {
+	return !watermark.IsZero() && startedAt.Before(watermark.Add(-maxGameDuration))
+}
+
+// sessionLockClass namespaces the per-player advisory locks that serialize
+// session updates; the second key is a hash of the username.
+const sessionLockClass = 5050
+
+// lockPlayerSessions waits for username's session lock, held until tx ends,
+// so that consumers of different partitions don't add games to the same
+// player's sessions at once. Callers locking several players lock them in
+// sorted order.
+func lockPlayerSessions(tx *sql.Tx, username string) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, sessionLockClass, username)
+	return err
+}
+
+type openSession struct {
+	ID        string
+	Username  string
+	StartedAt time.Time
+	EndedAt   time.Time
+	Games     int
+}
+
+// addToSession puts a game played from startedAt to endedAt into username's
+// open session within Gap of it. Games can arrive out of order, so a game may
+// extend a session at either end or bridge two sessions, which are merged.
+// The sessions are locked so closeSessions leaves them open meanwhile.
+func (a *AnalyticsDB) addToSession(tx *sql.Tx, username, gameID string, startedAt, endedAt time.Time) error {
+	rows, err := tx.Query(`
+		SELECT id, started_at, ended_at, games FROM analytics_sessions
+		WHERE username = $1 AND status = $2 AND started_at <= $3 AND ended_at >= $4
+		ORDER BY started_at
+		FOR UPDATE
+	`, username, sessionOpen, endedAt.Add(a.sessions.Gap), startedAt.Add(-a.sessions.Gap))
+	if err != nil {
+		return err
+	}
+	var touching []openSession
+	for rows.Next() {
+		var s openSession
+		if err := rows.Scan(&s.ID, &s.StartedAt, &s.EndedAt, &s.Games); err != nil {
+			rows.Close()
+			return err
+		}
+		touching = append(touching, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(touching) == 0 {
+		_, err := tx.Exec(`
+			INSERT INTO analytics_sessions (id, username, started_at, ended_at, status, games)
+			VALUES ($1, $2, $3, $4, $5, 1)
+		`, username+"/"+gameID, username, startedAt, endedAt, sessionOpen)
+		return err
+	}
+
+	merged := touching[0]
+	merged.Games++
+	for _, s := range touching {
+		if s.StartedAt.Before(startedAt) {
+			startedAt = s.StartedAt
+		}
+		if s.EndedAt.After(endedAt) {
+			endedAt = s.EndedAt
+		}
+	}
+	for _, s := range touching[1:] {
+		merged.Games += s.Games
+		if _, err := tx.Exec(`DELETE FROM analytics_sessions WHERE id = $1`, s.ID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE analytics_sessions SET started_at = $1, ended_at = $2, games = $3
+		WHERE id = $4
+	`, startedAt.UTC(), endedAt.UTC(), merged.Games, merged.ID)
+	return err
+}
+
+// closeSessions closes every open session that no game that isn't late can
+// extend any more, filling in its metrics. Sessions another transaction
+// is adding a game to are skipped rather than waited for; that transaction
+// closes sessions itself before it commits.
+func (a *AnalyticsDB) closeSessions(tx *sql.Tx, watermark time.Time) error {
+	if watermark.IsZero() {
+		return nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, username, started_at, ended_at FROM analytics_sessions
+		WHERE status = $1 AND ended_at < $2
+		FOR UPDATE SKIP LOCKED
+	`, sessionOpen, watermark.Add(-a.sessions.Gap-a.sessions.MaxGameDuration))
+	if err != nil {
+		return err
+	}
+	var closing []openSession
+	for rows.Next() {
+		var s openSession
+		if err := rows.Scan(&s.ID, &s.Username, &s.StartedAt, &s.EndedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		closing = append(closing, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range closing {
+		if err := a.closeSession(tx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionGame is one game of a session, from the player's side.
+type sessionGame struct {
+	Result string // "win", "loss" or "draw"
+	Reason string
+}
+
+func (a *AnalyticsDB) closeSession(tx *sql.Tx, s openSession) error {
+	rows, err := tx.Query(`
+		SELECT winner, end_reason FROM analytics_games
+		WHERE (first_mover = $1 OR (second_mover = $1 AND NOT is_bot))
+			AND completed_at >= $2 AND completed_at <= $3 AND NOT late
+		ORDER BY completed_at, game_id
+	`, s.Username, s.StartedAt, s.EndedAt)
+	if err != nil {
+		return err
+	}
+	var games []sessionGame
+	for rows.Next() {
+		var winner string
+		var g sessionGame
+		if err := rows.Scan(&winner, &g.Reason); err != nil {
+			rows.Close()
+			return err
+		}
+		switch winner {
+		case s.Username:
+			g.Result = "win"
+		case "draw":
+			g.Result = "draw"
+		default:
+			g.Result = "loss"
+		}
+		games = append(games, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	m := sessionMetrics(games)
+	_, err = tx.Exec(`
+		UPDATE analytics_sessions SET status = $1, games = $2, wins = $3, losses = $4, draws = $5,
+			rage_quits = $6, ended_on_rage_quit = $7, final_loss_streak = $8, closed_at = $9
+		WHERE id = $10
+	`, sessionClosed, len(games), m.wins, m.losses, m.draws, m.rageQuits, m.endedOnRageQuit, m.finalLossStreak, time.Now().UTC(), s.ID)
+	return err
+}
+
+type sessionTally struct {
+	wins, losses, draws int
+	rageQuits           int
+	endedOnRageQuit     bool
+	finalLossStreak     int
+}
+
+// sessionMetrics tallies a session's games in order. A rage quit is a game
+// forfeited by disconnecting right after a loss.
+func sessionMetrics(games []sessionGame) sessionTally {
+	var m sessionTally
+	for i, g := range games {
+		rageQuit := false
+		switch g.Result {
+		case "win":
+			m.wins++
+			m.finalLossStreak = 0
+		case "draw":
+			m.draws++
+			m.finalLossStreak = 0
+		case "loss":
+			m.losses++
+			m.finalLossStreak++
+			rageQuit = g.Reason == eventschema.ReasonDisconnectForfeit && i > 0 && games[i-1].Result == "loss"
+		}
+		if rageQuit {
+			m.rageQuits++
+		}
+		m.endedOnRageQuit = rageQuit
+	}
+	return m
+}
+
+// rebuildSessions recomputes every session from analytics_games in event-time
+// order, so nothing counts as late.
+func (a *AnalyticsDB) rebuildSessions(tx *sql.Tx) error {
+	for _, stmt := range []string{
+		`DELETE FROM analytics_sessions`,
+		`UPDATE analytics_games SET late = FALSE`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	type game struct {
+		id                      string
+		firstMover, secondMover string
+		isBot                   bool
+		duration                int
+		completedAt             time.Time
+	}
+	rows, err := tx.Query(`
+		SELECT game_id, first_mover, second_mover, is_bot, duration_sec, completed_at
+		FROM analytics_games
+		ORDER BY completed_at, game_id
+	`)
+	if err != nil {
+		return err
+	}
+	var games []game
+	for rows.Next() {
+		var g game
+		if err := rows.Scan(&g.id, &g.firstMover, &g.secondMover, &g.isBot, &g.duration, &g.completedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		games = append(games, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, g := range games {
+		completedAt := g.completedAt.UTC()
+		startedAt := completedAt.Add(-time.Duration(g.duration) * time.Second)
+		for _, username := range humanPlayers(g.firstMover, g.secondMover, g.isBot) {
+			if err := a.addToSession(tx, username, g.id, startedAt, completedAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	watermark, err := a.watermark(tx)
+	if err != nil {
+		return err
+	}
+	return a.closeSessions(tx, watermark)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"eventschema"
+)
+
+func TestIsLate(t *testing.T) {
+	watermark := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		startedAt time.Time
+		watermark time.Time
+		want      bool
+	}{
+		{"no watermark yet", watermark.Add(-24 * time.Hour), time.Time{}, false},
+		{"after the watermark", watermark.Add(time.Minute), watermark, false},
+		{"behind the watermark", watermark.Add(-59 * time.Minute), watermark, false},
+		{"a game length behind the watermark", watermark.Add(-time.Hour), watermark, false},
+		{"further behind", watermark.Add(-time.Hour - time.Second), watermark, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLate(tt.startedAt, tt.watermark, time.Hour); got != tt.want {
+				t.Fatalf("isLate(%v, %v) = %v, want %v", tt.startedAt, tt.watermark, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionMetrics(t *testing.T) {
+	win := sessionGame{Result: "win"}
+	loss := sessionGame{Result: "loss"}
+	draw := sessionGame{Result: "draw"}
+	forfeit := sessionGame{Result: "loss", Reason: eventschema.ReasonDisconnectForfeit}
+
+	tests := []struct {
+		name  string
+		games []sessionGame
+		want  sessionTally
+	}{
+		{"empty", nil, sessionTally{}},
+		{"one of each", []sessionGame{win, loss, draw}, sessionTally{wins: 1, losses: 1, draws: 1}},
+		{"loss streak at the end", []sessionGame{loss, win, loss, loss, loss}, sessionTally{wins: 1, losses: 4, finalLossStreak: 3}},
+		{"draw ends a loss streak", []sessionGame{loss, loss, draw}, sessionTally{losses: 2, draws: 1}},
+		{"forfeit after a loss is a rage quit", []sessionGame{loss, forfeit}, sessionTally{losses: 2, rageQuits: 1, endedOnRageQuit: true, finalLossStreak: 2}},
+		{"forfeit first is not a rage quit", []sessionGame{forfeit, loss}, sessionTally{losses: 2, finalLossStreak: 2}},
+		{"forfeit after a win is not a rage quit", []sessionGame{win, forfeit}, sessionTally{wins: 1, losses: 1, finalLossStreak: 1}},
+		{"rage quit mid-session", []sessionGame{loss, forfeit, win}, sessionTally{wins: 1, losses: 2, rageQuits: 1}},
+		{"repeated rage quits", []sessionGame{loss, forfeit, forfeit}, sessionTally{losses: 3, rageQuits: 2, endedOnRageQuit: true, finalLossStreak: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionMetrics(tt.games); got != tt.want {
+				t.Fatalf("sessionMetrics = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSessionSpansLongGame plays a game that starts 29 minutes after the
+// previous one ended and lasts 6, while other players' games move the
+// watermark more than a Gap past the end of the first. Both games belong to
+// one session. Watermarks are passed in directly and the transaction is
+// rolled back, so the test doesn't depend on other data in the database.
+func TestSessionSpansLongGame(t *testing.T) {
+	a := openTestDB(t)
+	a.sessions = SessionConfig{Gap: 30 * time.Minute, AllowedLateness: 5 * time.Minute, MaxGameDuration: time.Hour}
+
+	tx, err := a.conn.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	alice, bob := "alice-"+suffix, "bob-"+suffix
+	first := time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)
+	game := func(id string, completedAt time.Time, duration time.Duration) GameEvent {
+		return GameEvent{
+			EventID:    id + "-" + suffix,
+			EventType:  eventschema.GameCompleted,
+			GameID:     id + "-" + suffix,
+			Player:     alice,
+			Opponent:   bob,
+			GameResult: alice,
+			Duration:   int(duration.Seconds()),
+			Timestamp:  completedAt,
+		}
+	}
+
+	if err := a.recordGame(tx, game("g1", first, time.Minute), first); err != nil {
+		t.Fatal(err)
+	}
+	watermark := first.Add(31 * time.Minute)
+	if err := a.closeSessions(tx, watermark); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.recordGame(tx, game("g2", first.Add(35*time.Minute), 6*time.Minute), watermark); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.closeSessions(tx, first.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT status, games, wins, started_at, ended_at FROM analytics_sessions WHERE username = $1
+	`, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var sessions []string
+	for rows.Next() {
+		var status string
+		var games, wins int
+		var startedAt, endedAt time.Time
+		if err := rows.Scan(&status, &games, &wins, &startedAt, &endedAt); err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, fmt.Sprintf("%s %d/%d %s-%s", status, games, wins, startedAt.UTC().Format("15:04"), endedAt.UTC().Format("15:04")))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "closed 2/2 09:59-10:35"; len(sessions) != 1 || sessions[0] != want {
+		t.Fatalf("sessions = %q, want [%q]", sessions, want)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	quickWinMoves      = 11 // total moves in the game, both players' included
+	achievementHistory = 100
+)
+
+// Achievement is an unlocked achievement as shown to players.
+type Achievement struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	UnlockedAt  time.Time `json:"unlockedAt"`
+}
+
+// UnlockedAchievement is what stores persist: which achievement, and when.
+type UnlockedAchievement struct {
+	ID         string
+	UnlockedAt time.Time
+}
+
+type AchievementUnlockedMessage struct {
+	Achievements []Achievement `json:"achievements"`
+}
+
+// playerProgress is everything a rule can look at: the game just saved, from
+// one player's side, and that player's record including it.
+type playerProgress struct {
+	Username    string
+	Game        *GameState
+	Result      string // "win", "loss" or "draw"
+	GamesPlayed int
+	Wins        int
+	WinStreak   int // consecutive wins up to and including this game
+}
+
+type achievementRule struct {
+	ID          string
+	Name        string
+	Description string
+	Unlocked    func(p *playerProgress) bool
+}
+
+// achievementRules are checked for each human player every time a game is
+// saved. IDs are persisted, so never change or reuse one.
+var achievementRules = []achievementRule{
+	{
+		ID:          "first_win",
+		Name:        "First Blood",
+		Description: "Win your first game",
+		Unlocked:    func(p *playerProgress) bool { return p.Wins >= 1 },
+	},
+	{
+		ID:          "win_streak_3",
+		Name:        "On a Roll",
+		Description: "Win 3 games in a row",
+		Unlocked:    func(p *playerProgress) bool { return p.WinStreak >= 3 },
+	},
+	{
+		ID:          "win_streak_5",
+		Name:        "Unstoppable",
+		Description: "Win 5 games in a row",
+		Unlocked:    func(p *playerProgress) bool { return p.WinStreak >= 5 },
+	},
+	{
+		ID:          "beat_hard_bot",
+		Name:        "Machine Breaker",
+		Description: "Beat the hard bot",
+		Unlocked: func(p *playerProgress) bool {
+			return p.Result == "win" && p.Game.IsBot && p.Game.BotDifficulty == "hard"
+		},
+	},
+	{
+		ID:          "quick_win",
+		Name:        "Blitz",
+		Description: "Win a game that lasts 11 moves or fewer",
+		Unlocked: func(p *playerProgress) bool {
+			return p.Result == "win" && len(p.Game.Moves) <= quickWinMoves
+		},
+	},
+	{
+		ID:          "comeback",
+		Name:        "Comeback",
+		Description: "Win after your opponent had three in a row with room for a fourth",
+		Unlocked: func(p *playerProgress) bool {
+			return p.Result == "win" && opponentThreatened(p.Game, p.Username)
+		},
+	},
+	{
+		ID:          "first_draw",
+		Name:        "Stalemate",
+		Description: "Fill the board without a winner",
+		Unlocked:    func(p *playerProgress) bool { return p.Result == "draw" },
+	},
+	{
+		ID:          "games_10",
+		Name:        "Regular",
+		Description: "Play 10 games",
+		Unlocked:    func(p *playerProgress) bool { return p.GamesPlayed >= 10 },
+	},
+	{
+		ID:          "games_100",
+		Name:        "Veteran",
+		Description: "Play 100 games",
+		Unlocked:    func(p *playerProgress) bool { return p.GamesPlayed >= 100 },
+	},
+}
+
+var achievementRulesByID = func() map[string]achievementRule {
+	byID := make(map[string]achievementRule, len(achievementRules))
+	for _, rule := range achievementRules {
+		byID[rule.ID] = rule
+	}
+	return byID
+}()
+
+// newAchievement describes an unlocked achievement using its rule.
+func newAchievement(unlocked UnlockedAchievement) Achievement {
+	rule := achievementRulesByID[unlocked.ID]
+	return Achievement{
+		ID:          unlocked.ID,
+		Name:        rule.Name,
+		Description: rule.Description,
+		UnlockedAt:  unlocked.UnlockedAt,
+	}
+}
+
+// GetAchievements returns a player's unlocked achievements, oldest first.
+// Achievements whose rule has since been removed are left out.
+func GetAchievements(db Store, username string) ([]Achievement, error) {
+	unlocked, err := db.GetAchievements(username)
+	if err != nil {
+		return nil, err
+	}
+
+	achievements := make([]Achievement, 0, len(unlocked))
+	for _, u := range unlocked {
+		if _, ok := achievementRulesByID[u.ID]; ok {
+			achievements = append(achievements, newAchievement(u))
+		}
+	}
+	return achievements, nil
+}
+
+// EvaluateAchievements checks every rule for the human players of a saved game
+// and persists what they unlocked. It returns the newly unlocked achievements
+// per player.
+func EvaluateAchievements(db Store, game *GameState) map[string][]Achievement {
+	players := []string{game.Player1}
+	if !game.IsBot {
+		players = append(players, game.Player2)
+	}
+
+	unlocked := make(map[string][]Achievement)
+	for _, username := range players {
+		progress, err := loadProgress(db, username, game)
+		if err != nil {
+			log.Printf("Error loading progress for %s: %v\n", username, err)
+			continue
+		}
+
+		var ids []string
+		for _, rule := range achievementRules {
+			if rule.Unlocked(progress) {
+				ids = append(ids, rule.ID)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		added, err := db.UnlockAchievements(username, ids, time.Now())
+		if err != nil {
+			log.Printf("Error saving achievements for %s: %v\n", username, err)
+			continue
+		}
+		for _, u := range added {
+			unlocked[username] = append(unlocked[username], newAchievement(u))
+		}
+	}
+	return unlocked
+}
+
+// loadProgress reads username's record after game was recorded.
+func loadProgress(db Store, username string, game *GameState) (*playerProgress, error) {
+	stats, err := db.GetPlayerStats(username)
+	if err != nil {
+		return nil, err
+	}
+	wins, _ := stats["wins"].(int)
+	losses, _ := stats["losses"].(int)
+	draws, _ := stats["draws"].(int)
+
+	recent, err := db.ListPlayerGames(GameQuery{Username: username, Limit: achievementHistory})
+	if err != nil {
+		return nil, err
+	}
+	streak := 0
+	for _, g := range recent {
+		if g.Result != "win" {
+			break
+		}
+		streak++
+	}
+
+	return &playerProgress{
+		Username:    username,
+		Game:        game,
+		Result:      gameResult(game.Winner, username),
+		GamesPlayed: wins + losses + draws,
+		Wins:        wins,
+		WinStreak:   streak,
+	}, nil
+}
+
+// opponentThreatened replays the game and reports whether username's opponent
+// ever had three discs in a line of four whose last cell was still empty.
+func opponentThreatened(game *GameState, username string) bool {
+	opponent := PLAYER2
+	if game.Player2 == username {
+		opponent = PLAYER1
+	}
+
+	board := NewBoard()
+	for _, move := range game.Moves {
+		if move.Row < 0 || move.Row >= ROWS || move.Column < 0 || move.Column >= COLS {
+			return false
+		}
+		board.Grid[move.Row][move.Column] = move.Player
+		if move.Player == opponent && hasOpenThree(board, opponent) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOpenThree reports whether player has three discs in any line of four
+// cells whose fourth cell is empty.
+func hasOpenThree(board *Board, player int) bool {
+	directions := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+	for row := 0; row < ROWS; row++ {
+		for col := 0; col < COLS; col++ {
+			for _, d := range directions {
+				endRow, endCol := row+3*d[0], col+3*d[1]
+				if endRow >= ROWS || endCol < 0 || endCol >= COLS {
+					continue
+				}
+
+				mine, empty := 0, 0
+				for i := 0; i < 4; i++ {
+					switch board.Grid[row+i*d[0]][col+i*d[1]] {
+					case player:
+						mine++
+					case EMPTY:
+						empty++
+					}
+				}
+				if mine == 3 && empty == 1 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
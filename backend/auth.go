@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlayerTokenMessage hands a player the token that proves they own their
+// username. It is sent once, when the username is first registered.
+type PlayerTokenMessage struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssuePlayerToken claims username for whoever registers it first and returns
+// their token, or "" if the username was already claimed. Only the token's
+// hash is stored.
+func (gm *GameManager) IssuePlayerToken(username string) string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Error generating token for %s: %v\n", username, err)
+		return ""
+	}
+	token := hex.EncodeToString(buf)
+
+	claimed, err := gm.db.ClaimPlayerToken(username, hashToken(token))
+	if err != nil {
+		log.Printf("Error storing token for %s: %v\n", username, err)
+		return ""
+	}
+	if !claimed {
+		return ""
+	}
+	return token
+}
+
+// authorizePlayer checks that the request carries username's token as
+// "Authorization: Bearer <token>". If not, it writes the error response and
+// returns false.
+func (s *Server) authorizePlayer(c *gin.Context, username string) bool {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing player token"})
+		return false
+	}
+
+	hash, err := s.db.PlayerTokenHash(username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check player token"})
+		return false
+	}
+	if hash == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(hashToken(token))) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not this player's token"})
+		return false
+	}
+	return true
+}
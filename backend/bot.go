@@ -2,6 +2,16 @@ package main
 
 import (
 	"math"
+	"math/rand"
+)
+
+const (
+	// easyRandomMoveRate is how often the easy bot plays a random column
+	// instead of its best one.
+	easyRandomMoveRate = 0.5
+	// hardSearchDepth is how many moves ahead the hard bot looks.
+	hardSearchDepth = 6
+	winScore        = 1000000
 )
 
 type Bot struct {
@@ -23,6 +33,15 @@ func (bot *Bot) GetBotMove(board *Board, botPlayer int, opponentPlayer int) int
 		return -1
 	}
 
+	switch bot.Difficulty {
+	case "easy":
+		if rand.Float64() < easyRandomMoveRate {
+			return validMoves[rand.Intn(len(validMoves))]
+		}
+	case "hard":
+		return bot.searchMove(board, botPlayer, opponentPlayer)
+	}
+
 	bestScore := math.MinInt32
 	bestMove := validMoves[0]
 
@@ -120,6 +139,127 @@ func (bot *Bot) countAdjacentDiscs(board *Board, row, col, player int) int {
 	return count
 }
 
+// searchMove picks the column with the best outcome hardSearchDepth moves
+// ahead, assuming the opponent plays their best too.
+func (bot *Bot) searchMove(board *Board, botPlayer int, opponentPlayer int) int {
+	bestScore := math.MinInt32
+	bestMove := -1
+	alpha, beta := -math.MaxInt32, math.MaxInt32
+
+	for _, col := range centerFirst(board.GetValidMoves()) {
+		score := -math.MaxInt32
+		testBoard := board.Copy()
+		if row, err := testBoard.DropDisc(col, botPlayer); err == nil {
+			score = -negamax(testBoard, row, col, hardSearchDepth-1, -beta, -alpha, opponentPlayer, botPlayer)
+		}
+		if score > bestScore {
+			bestScore = score
+			bestMove = col
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+
+	return bestMove
+}
+
+// negamax scores board for player, who is about to move, after opponent
+// dropped a disc at (row, col). Quicker wins score higher.
+func negamax(board *Board, row, col, depth, alpha, beta, player, opponent int) int {
+	if board.CheckWin(row, col, opponent) {
+		return -(winScore + depth)
+	}
+	if board.IsBoardFull() {
+		return 0
+	}
+	if depth == 0 {
+		return evaluateBoard(board, player, opponent)
+	}
+
+	best := -math.MaxInt32
+	for _, c := range centerFirst(board.GetValidMoves()) {
+		testBoard := board.Copy()
+		r, err := testBoard.DropDisc(c, player)
+		if err != nil {
+			continue
+		}
+		score := -negamax(testBoard, r, c, depth-1, -beta, -alpha, opponent, player)
+		if score > best {
+			best = score
+		}
+		if best > alpha {
+			alpha = best
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return best
+}
+
+// evaluateBoard scores every line of four for player: open threes and twos
+// count for, the opponent's against, and center discs are worth a little.
+func evaluateBoard(board *Board, player, opponent int) int {
+	score := 0
+	for r := 0; r < ROWS; r++ {
+		if board.Grid[r][COLS/2] == player {
+			score += 3
+		} else if board.Grid[r][COLS/2] == opponent {
+			score -= 3
+		}
+	}
+
+	directions := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+	for r := 0; r < ROWS; r++ {
+		for c := 0; c < COLS; c++ {
+			for _, dir := range directions {
+				endRow, endCol := r+3*dir[0], c+3*dir[1]
+				if endRow < 0 || endRow >= ROWS || endCol < 0 || endCol >= COLS {
+					continue
+				}
+				mine, theirs := 0, 0
+				for i := 0; i < 4; i++ {
+					switch board.Grid[r+i*dir[0]][c+i*dir[1]] {
+					case player:
+						mine++
+					case opponent:
+						theirs++
+					}
+				}
+				score += windowScore(mine, theirs)
+			}
+		}
+	}
+	return score
+}
+
+func windowScore(mine, theirs int) int {
+	switch {
+	case theirs == 0 && mine == 3:
+		return 50
+	case theirs == 0 && mine == 2:
+		return 10
+	case mine == 0 && theirs == 3:
+		return -80
+	case mine == 0 && theirs == 2:
+		return -10
+	}
+	return 0
+}
+
+// centerFirst orders columns from the center out, so the search meets
+// strong moves early and prunes more.
+func centerFirst(cols []int) []int {
+	ordered := append([]int(nil), cols...)
+	for i := 1; i < len(ordered); i++ {
+		for j := i; j > 0 && abs(ordered[j]-COLS/2) < abs(ordered[j-1]-COLS/2); j-- {
+			ordered[j], ordered[j-1] = ordered[j-1], ordered[j]
+		}
+	}
+	return ordered
+}
+
 func abs(x int) int {
 	if x < 0 {
 		return -x
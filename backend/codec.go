@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"4-in-a-row/wire"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes outgoing and decodes incoming WebSocket frames. The codec for
+// a connection is negotiated through the WebSocket subprotocol; clients that
+// don't ask for one get JSON.
+type Codec interface {
+	// Name is the subprotocol that selects this codec.
+	Name() string
+	// FrameType is websocket.TextMessage or websocket.BinaryMessage.
+	FrameType() int
+	Encode(msg interface{}) ([]byte, error)
+	Decode(data []byte) (*InboundMessage, error)
+}
+
+var codecs = []Codec{
+	jsonCodec{},
+	newMsgpackCodec(),
+	protobufCodec{},
+}
+
+// Subprotocols lists the subprotocols offered during the upgrade, in order of
+// preference.
+func Subprotocols() []string {
+	names := make([]string, len(codecs))
+	for i, c := range codecs {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// CodecFor returns the codec for a negotiated subprotocol, falling back to JSON.
+func CodecFor(subprotocol string) Codec {
+	for _, c := range codecs {
+		if c.Name() == subprotocol {
+			return c
+		}
+	}
+	return codecs[0]
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string   { return "json" }
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+func (jsonCodec) Encode(msg interface{}) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (jsonCodec) Decode(data []byte) (*InboundMessage, error) {
+	var msg InboundMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// msgpackCodec uses the same field names as the JSON encoding.
+type msgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func newMsgpackCodec() msgpackCodec {
+	h := &codec.MsgpackHandle{}
+	h.WriteExt = true
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return msgpackCodec{handle: h}
+}
+
+func (msgpackCodec) Name() string   { return "msgpack" }
+func (msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (c msgpackCodec) Encode(msg interface{}) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, c.handle).Encode(msg)
+	return data, err
+}
+
+func (c msgpackCodec) Decode(data []byte) (*InboundMessage, error) {
+	var raw struct {
+		Type      string      `codec:"type"`
+		RequestID string      `codec:"requestId"`
+		Payload   interface{} `codec:"payload"`
+	}
+	if err := codec.NewDecoderBytes(data, c.handle).Decode(&raw); err != nil {
+		return nil, err
+	}
+	return inboundFromGeneric(raw.Type, raw.RequestID, raw.Payload)
+}
+
+// protobufCodec encodes messages as the ServerMessage and ClientMessage types
+// in wire/wire.proto.
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string   { return "protobuf" }
+func (protobufCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (protobufCodec) Encode(msg interface{}) ([]byte, error) {
+	m, ok := msg.(*Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: unsupported message %T", msg)
+	}
+
+	out := &wire.ServerMessage{Type: m.Type, RequestId: m.RequestID, Seq: m.Seq}
+	switch p := m.Payload.(type) {
+	case AckMessage:
+		out.Payload = &wire.ServerMessage_Ack{Ack: &wire.Ack{Type: p.Type, Seq: p.Seq}}
+	case ErrorMessage:
+		out.Payload = &wire.ServerMessage_Error{Error: &wire.Error{Message: p.Message}}
+	case GameStartMessage:
+		out.Payload = &wire.ServerMessage_GameStart{GameStart: &wire.GameStart{
+			GameId:   p.GameID,
+			Player1:  p.Player1,
+			Player2:  p.Player2,
+			IsBot:    p.IsBot,
+			YourTurn: p.YourTurn,
+		}}
+	case GameMoveEventMessage:
+		out.Payload = &wire.ServerMessage_GameMove{GameMove: &wire.GameMove{
+			GameId:        p.GameID,
+			Column:        int32(p.Column),
+			Row:           int32(p.Row),
+			Player:        int32(p.Player),
+			Board:         boardCells(p.Board),
+			CurrentPlayer: int32(p.CurrentPlayer),
+		}}
+	case GameSnapshotMessage:
+		out.Payload = &wire.ServerMessage_GameSnapshot{GameSnapshot: &wire.GameSnapshot{
+			GameId:        p.GameID,
+			Player1:       p.Player1,
+			Player2:       p.Player2,
+			IsBot:         p.IsBot,
+			Board:         boardCells(&p.Board),
+			CurrentPlayer: int32(p.CurrentPlayer),
+			Status:        p.Status,
+			Winner:        p.Winner,
+			Seq:           p.Seq,
+		}}
+	case GameResultMessage:
+		out.Payload = &wire.ServerMessage_GameResult{GameResult: &wire.GameResult{
+			GameId: p.GameID,
+			Winner: p.Winner,
+			WinRow: int32(p.WinRow),
+			WinCol: int32(p.WinCol),
+		}}
+	case AchievementUnlockedMessage:
+		unlocked := &wire.AchievementUnlocked{}
+		for _, a := range p.Achievements {
+			unlocked.Achievements = append(unlocked.Achievements, &wire.Achievement{
+				Id:          a.ID,
+				Name:        a.Name,
+				Description: a.Description,
+				UnlockedAt:  a.UnlockedAt.UnixMilli(),
+			})
+		}
+		out.Payload = &wire.ServerMessage_AchievementUnlocked{AchievementUnlocked: unlocked}
+	case PlayerTokenMessage:
+		out.Payload = &wire.ServerMessage_PlayerToken{PlayerToken: &wire.PlayerToken{Username: p.Username, Token: p.Token}}
+	default:
+		return nil, fmt.Errorf("protobuf: unsupported payload %T", m.Payload)
+	}
+	return proto.Marshal(out)
+}
+
+func (protobufCodec) Decode(data []byte) (*InboundMessage, error) {
+	var in wire.ClientMessage
+	if err := proto.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+
+	var payload interface{}
+	switch p := in.Payload.(type) {
+	case *wire.ClientMessage_Register:
+		payload = map[string]string{"username": p.Register.GetUsername()}
+	case *wire.ClientMessage_GameMove:
+		payload = GameMoveMessage{Column: int(p.GameMove.GetColumn())}
+	case *wire.ClientMessage_Rejoin:
+		payload = map[string]string{"gameId": p.Rejoin.GetGameId()}
+	case *wire.ClientMessage_Snapshot:
+		payload = map[string]string{"gameId": p.Snapshot.GetGameId()}
+	case *wire.ClientMessage_Resync:
+		payload = ResyncMessage{GameID: p.Resync.GetGameId(), FromSeq: p.Resync.GetFromSeq()}
+	}
+	return inboundFromGeneric(in.Type, in.RequestId, payload)
+}
+
+// boardCells flattens a board row by row; nil stays empty.
+func boardCells(grid *[ROWS][COLS]int) []int32 {
+	if grid == nil {
+		return nil
+	}
+	cells := make([]int32, 0, ROWS*COLS)
+	for _, row := range grid {
+		for _, cell := range row {
+			cells = append(cells, int32(cell))
+		}
+	}
+	return cells
+}
+
+// inboundFromGeneric re-encodes a decoded payload as JSON so message handlers
+// stay independent of the wire format.
+func inboundFromGeneric(msgType string, requestID string, payload interface{}) (*InboundMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &InboundMessage{Type: msgType, RequestID: requestID, Payload: data}, nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"4-in-a-row/wire"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCodecFor(t *testing.T) {
+	for _, name := range []string{"json", "msgpack", "protobuf"} {
+		if got := CodecFor(name).Name(); got != name {
+			t.Fatalf("CodecFor(%q) = %q", name, got)
+		}
+	}
+	if got := CodecFor("").Name(); got != "json" {
+		t.Fatalf("CodecFor(\"\") = %q, want json", got)
+	}
+	if got := CodecFor("xml").Name(); got != "json" {
+		t.Fatalf("CodecFor(\"xml\") = %q, want json", got)
+	}
+}
+
+// TestCodecDecode decodes the same client messages in every wire format and
+// checks handlers see the same payload.
+func TestCodecDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		msgType     string
+		payload     map[string]interface{}
+		proto       *wire.ClientMessage
+		wantPayload string
+	}{
+		{
+			name:        "register",
+			msgType:     "register",
+			payload:     map[string]interface{}{"username": "alice"},
+			proto:       &wire.ClientMessage{Payload: &wire.ClientMessage_Register{Register: &wire.Register{Username: "alice"}}},
+			wantPayload: `{"username":"alice"}`,
+		},
+		{
+			name:        "move",
+			msgType:     "game_move",
+			payload:     map[string]interface{}{"column": 3},
+			proto:       &wire.ClientMessage{Payload: &wire.ClientMessage_GameMove{GameMove: &wire.Move{Column: 3}}},
+			wantPayload: `{"column":3}`,
+		},
+		{
+			name:        "move to column 0",
+			msgType:     "game_move",
+			payload:     map[string]interface{}{"column": 0},
+			proto:       &wire.ClientMessage{Payload: &wire.ClientMessage_GameMove{GameMove: &wire.Move{}}},
+			wantPayload: `{"column":0}`,
+		},
+		{
+			name:        "rejoin",
+			msgType:     "rejoin",
+			payload:     map[string]interface{}{"gameId": "g1"},
+			proto:       &wire.ClientMessage{Payload: &wire.ClientMessage_Rejoin{Rejoin: &wire.Rejoin{GameId: "g1"}}},
+			wantPayload: `{"gameId":"g1"}`,
+		},
+		{
+			name:        "resync",
+			msgType:     "resync",
+			payload:     map[string]interface{}{"gameId": "g1", "fromSeq": 12},
+			proto:       &wire.ClientMessage{Payload: &wire.ClientMessage_Resync{Resync: &wire.Resync{GameId: "g1", FromSeq: 12}}},
+			wantPayload: `{"fromSeq":12,"gameId":"g1"}`,
+		},
+	}
+	for _, tt := range tests {
+		msg := map[string]interface{}{"type": tt.msgType, "requestId": "r1", "payload": tt.payload}
+		jsonFrame, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgpackFrame, err := CodecFor("msgpack").Encode(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.proto.Type, tt.proto.RequestId = tt.msgType, "r1"
+		protoFrame, err := proto.Marshal(tt.proto)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frames := map[string][]byte{"json": jsonFrame, "msgpack": msgpackFrame, "protobuf": protoFrame}
+		for name, frame := range frames {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				got, err := CodecFor(name).Decode(frame)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Type != tt.msgType || got.RequestID != "r1" {
+					t.Fatalf("decoded %q/%q, want %q/r1", got.Type, got.RequestID, tt.msgType)
+				}
+				if normalizeJSON(t, got.Payload) != normalizeJSON(t, []byte(tt.wantPayload)) {
+					t.Fatalf("payload = %s, want %s", got.Payload, tt.wantPayload)
+				}
+			})
+		}
+	}
+}
+
+func TestCodecDecodeInvalid(t *testing.T) {
+	for _, name := range []string{"json", "msgpack", "protobuf"} {
+		if _, err := CodecFor(name).Decode([]byte{0xff, 0xff, 0xff}); err == nil {
+			t.Fatalf("%s decoded garbage", name)
+		}
+	}
+}
+
+// normalizeJSON re-encodes data so field order and number formats don't
+// matter when comparing.
+func normalizeJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("%s: %v", data, err)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func testBoard() *[ROWS][COLS]int {
+	var grid [ROWS][COLS]int
+	grid[ROWS-1][3] = 1
+	grid[ROWS-1][4] = 2
+	grid[ROWS-2][3] = 1
+	return &grid
+}
+
+var testMessages = []*Message{
+	{Type: "ack", RequestID: "r1", Seq: 4, Payload: AckMessage{Type: "game_move", Seq: 4}},
+	{Type: "error", RequestID: "r2", Payload: ErrorMessage{Message: "Not your turn"}},
+	{Type: "game_start", Seq: 1, Payload: GameStartMessage{GameID: "g1", Player1: "alice", Player2: "bob", YourTurn: true}},
+	{Type: "game_move", Seq: 5, Payload: GameMoveEventMessage{GameID: "g1", Column: 3, Row: 4, Player: 1, Board: testBoard(), CurrentPlayer: 2}},
+	{Type: "game_move", Seq: 6, Payload: GameMoveEventMessage{GameID: "g1", Column: 4, Row: 5, Player: 2, CurrentPlayer: 1}},
+	{Type: "game_result", Seq: 9, Payload: GameResultMessage{GameID: "g1", Winner: "alice", WinRow: 2, WinCol: 3}},
+	{Type: "player_token", Payload: PlayerTokenMessage{Username: "alice", Token: "secret"}},
+}
+
+// TestMsgpackEncodeMatchesJSON checks msgpack frames carry the same fields as
+// JSON ones.
+func TestMsgpackEncodeMatchesJSON(t *testing.T) {
+	h := newMsgpackCodec().handle
+	for _, msg := range testMessages {
+		t.Run(msg.Type, func(t *testing.T) {
+			want, err := jsonCodec{}.Encode(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			frame, err := CodecFor("msgpack").Encode(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded interface{}
+			if err := codec.NewDecoderBytes(frame, h).Decode(&decoded); err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if normalizeJSON(t, got) != normalizeJSON(t, want) {
+				t.Fatalf("msgpack = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestProtobufEncode(t *testing.T) {
+	board := make([]int32, ROWS*COLS)
+	board[(ROWS-1)*COLS+3] = 1
+	board[(ROWS-1)*COLS+4] = 2
+	board[(ROWS-2)*COLS+3] = 1
+
+	want := []*wire.ServerMessage{
+		{Type: "ack", RequestId: "r1", Seq: 4, Payload: &wire.ServerMessage_Ack{Ack: &wire.Ack{Type: "game_move", Seq: 4}}},
+		{Type: "error", RequestId: "r2", Payload: &wire.ServerMessage_Error{Error: &wire.Error{Message: "Not your turn"}}},
+		{Type: "game_start", Seq: 1, Payload: &wire.ServerMessage_GameStart{GameStart: &wire.GameStart{GameId: "g1", Player1: "alice", Player2: "bob", YourTurn: true}}},
+		{Type: "game_move", Seq: 5, Payload: &wire.ServerMessage_GameMove{GameMove: &wire.GameMove{GameId: "g1", Column: 3, Row: 4, Player: 1, Board: board, CurrentPlayer: 2}}},
+		{Type: "game_move", Seq: 6, Payload: &wire.ServerMessage_GameMove{GameMove: &wire.GameMove{GameId: "g1", Column: 4, Row: 5, Player: 2, CurrentPlayer: 1}}},
+		{Type: "game_result", Seq: 9, Payload: &wire.ServerMessage_GameResult{GameResult: &wire.GameResult{GameId: "g1", Winner: "alice", WinRow: 2, WinCol: 3}}},
+		{Type: "player_token", Payload: &wire.ServerMessage_PlayerToken{PlayerToken: &wire.PlayerToken{Username: "alice", Token: "secret"}}},
+	}
+	for i, msg := range testMessages {
+		t.Run(msg.Type, func(t *testing.T) {
+			frame, err := CodecFor("protobuf").Encode(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got wire.ServerMessage
+			if err := proto.Unmarshal(frame, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(&got, want[i]) {
+				t.Fatalf("decoded %v, want %v", &got, want[i])
+			}
+		})
+	}
+
+	if _, err := CodecFor("protobuf").Encode(map[string]string{"type": "ping"}); err == nil {
+		t.Fatal("encoded a message that isn't a *Message")
+	}
+	if _, err := CodecFor("protobuf").Encode(&Message{Type: "ping", Payload: struct{}{}}); err == nil {
+		t.Fatal("encoded an unsupported payload")
+	}
+}
+
+func TestBoardCells(t *testing.T) {
+	if cells := boardCells(nil); cells != nil {
+		t.Fatalf("boardCells(nil) = %v, want nil", cells)
+	}
+	cells := boardCells(testBoard())
+	if len(cells) != ROWS*COLS {
+		t.Fatalf("%d cells, want %d", len(cells), ROWS*COLS)
+	}
+	want := map[int]int32{(ROWS-1)*COLS + 3: 1, (ROWS-1)*COLS + 4: 2, (ROWS-2)*COLS + 3: 1}
+	for i, cell := range cells {
+		if cell != want[i] {
+			t.Fatalf("cell %d = %d, want %d (board %v)", i, cell, want[i], cells)
+		}
+	}
+	if !reflect.DeepEqual(boardCells(&[ROWS][COLS]int{}), make([]int32, ROWS*COLS)) {
+		t.Fatal("empty board doesn't flatten to zeros")
+	}
+}
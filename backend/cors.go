@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Origins allowed by each ENVIRONMENT profile. ALLOWED_ORIGINS adds to these.
+var originProfiles = map[string][]string{
+	"development": {
+		"http://localhost:3000",
+		"http://localhost:5173",
+		"http://127.0.0.1:3000",
+		"http://127.0.0.1:5173",
+	},
+	"production": {
+		"https://emittr.onrender.com",
+	},
+}
+
+// OriginPolicy is the allowlist of browser origins, applied to both the
+// WebSocket upgrade and the REST CORS headers.
+type OriginPolicy struct {
+	profile string
+	origins map[string]bool
+}
+
+// LoadOriginPolicy builds the allowlist from the ENVIRONMENT profile
+// (development or production, the default) plus the comma-separated
+// ALLOWED_ORIGINS.
+func LoadOriginPolicy() *OriginPolicy {
+	profile := os.Getenv("ENVIRONMENT")
+	if _, ok := originProfiles[profile]; !ok {
+		profile = "production"
+	}
+
+	return NewOriginPolicy(profile, append(originProfiles[profile], strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",")...))
+}
+
+func NewOriginPolicy(profile string, origins []string) *OriginPolicy {
+	p := &OriginPolicy{profile: profile, origins: make(map[string]bool)}
+	for _, origin := range origins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			p.origins[origin] = true
+		}
+	}
+	return p
+}
+
+// List returns the allowed origins, sorted.
+func (p *OriginPolicy) List() []string {
+	list := make([]string, 0, len(p.origins))
+	for origin := range p.origins {
+		list = append(list, origin)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (p *OriginPolicy) Allowed(origin string) bool {
+	return p.origins[origin]
+}
+
+// CheckOrigin is the WebSocket upgrader hook. Requests without an Origin
+// header don't come from a browser and aren't subject to the allowlist.
+func (p *OriginPolicy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || p.Allowed(origin)
+}
+
+// Middleware sets CORS headers for allowed origins and rejects preflights from
+// any other origin.
+func (p *OriginPolicy) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		allowed := origin != "" && p.Allowed(origin)
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if allowed {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		}
+
+		if c.Request.Method == "OPTIONS" {
+			if !allowed {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -3,35 +3,34 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
+	"eventschema"
+
 	_ "github.com/lib/pq"
 )
 
+// Database is the Postgres Store. SQLiteStore reuses it, so queries here
+// stick to SQL both databases understand.
 type Database struct {
 	conn *sql.DB
 }
 
 func InitDB(dbURL string) (*Database, error) {
-	conn, err := sql.Open("postgres", dbURL)
+	conn, err := OpenDB(dbURL)
 	if err != nil {
 		return nil, err
 	}
 
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := conn.PingContext(ctx); err != nil {
-		return nil, err
-	}
-
 	db := &Database{conn: conn}
 
 	// Run migrations
-	if err := db.runMigrations(); err != nil {
+	if err := NewMigrator(conn).Up(0); err != nil {
+		conn.Close()
 		return nil, err
 	}
 
@@ -39,64 +38,55 @@ func InitDB(dbURL string) (*Database, error) {
 	return db, nil
 }
 
-func (db *Database) runMigrations() error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS players (
-			id SERIAL PRIMARY KEY,
-			username VARCHAR(255) UNIQUE NOT NULL,
-			wins INT DEFAULT 0,
-			losses INT DEFAULT 0,
-			draws INT DEFAULT 0,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS games (
-			id VARCHAR(36) PRIMARY KEY,
-			player1 VARCHAR(255),
-			player2 VARCHAR(255),
-			winner VARCHAR(255),
-			is_bot BOOLEAN DEFAULT false,
-			status VARCHAR(50),
-			board_state JSONB,
-			created_at TIMESTAMP,
-			updated_at TIMESTAMP,
-			duration_seconds INT
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_games_player1 ON games(player1)`,
-		`CREATE INDEX IF NOT EXISTS idx_games_player2 ON games(player2)`,
-		`CREATE INDEX IF NOT EXISTS idx_games_winner ON games(winner)`,
-		`CREATE INDEX IF NOT EXISTS idx_games_created_at ON games(created_at)`,
-	}
-
-	for _, migration := range migrations {
-		_, err := db.conn.Exec(migration)
-		if err != nil {
-			log.Printf("Migration error: %v\n", err)
-			// Continue even if some tables exist
-		}
+// OpenDB connects to Postgres without touching the schema.
+func OpenDB(dbURL string) (*sql.DB, error) {
+	conn, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	// Test connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
 }
 
-func (db *Database) SaveGame(game *GameState) error {
-	createdAt, _ := time.Parse(time.RFC3339, game.CreatedAt)
-	updatedAt, _ := time.Parse(time.RFC3339, game.UpdatedAt)
-	duration := int(updatedAt.Sub(createdAt).Seconds())
+// RecordResult saves a game, its moves, the players' stats and the outbox
+// events in one transaction. Stats are only applied on the game's first
+// transition to finished; recording the same result again is a no-op that
+// reports false.
+func (db *Database) RecordResult(game *GameState, events ...GameEvent) (bool, error) {
+	createdAt, updatedAt, duration := gameTimes(game)
 
 	boardJSON := fmt.Sprintf(`"%v"`, game.Board.Grid)
 
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// The WHERE clause turns the upsert into a no-op once the game is
+	// finished, so only the first recording affects a row.
 	query := `
 		INSERT INTO games (id, player1, player2, winner, is_bot, status, board_state, created_at, updated_at, duration_seconds)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		ON CONFLICT (id) DO UPDATE SET
 			winner = $4,
 			status = $6,
+			board_state = $7,
 			updated_at = $9,
 			duration_seconds = $10
+		WHERE COALESCE(games.status, '') <> 'finished'
 	`
 
-	_, err := db.conn.Exec(
+	res, err := tx.Exec(
 		query,
 		game.ID,
 		game.Player1,
@@ -109,139 +99,377 @@ func (db *Database) SaveGame(game *GameState) error {
 		updatedAt,
 		duration,
 	)
-
 	if err != nil {
 		log.Printf("Error saving game: %v\n", err)
-		return err
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		log.Printf("Game %s already recorded, skipping\n", game.ID)
+		return false, nil
+	}
+
+	for _, move := range game.Moves {
+		_, err := tx.Exec(`
+			INSERT INTO moves (game_id, move_number, player, col, row_num, played_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (game_id, move_number) DO NOTHING
+		`, game.ID, move.Number, move.Player, move.Column, move.Row, move.PlayedAt)
+		if err != nil {
+			return false, fmt.Errorf("saving move %d: %w", move.Number, err)
+		}
 	}
 
 	// Update player stats
-	if game.Winner != "" && game.Winner != "draw" {
-		if game.Winner == "Bot" {
-			err := db.IncrementLosses(game.Player1)
-			if err != nil {
-				log.Printf("Error updating losses for %s: %v\n", game.Player1, err)
+	if game.Status == "finished" {
+		wins, losses, draws := resultDeltas(game)
+		for _, username := range wins {
+			if err := incrementStat(tx, "wins", username); err != nil {
+				return false, fmt.Errorf("updating wins for %s: %w", username, err)
 			}
-		} else {
-			err := db.IncrementWins(game.Winner)
-			if err != nil {
-				log.Printf("Error updating wins for %s: %v\n", game.Winner, err)
+		}
+		for _, username := range losses {
+			if err := incrementStat(tx, "losses", username); err != nil {
+				return false, fmt.Errorf("updating losses for %s: %w", username, err)
 			}
-			if game.IsBot {
-				// No need to update bot stats
-			} else {
-				if game.Player1 == game.Winner {
-					err := db.IncrementLosses(game.Player2)
-					if err != nil {
-						log.Printf("Error updating losses for %s: %v\n", game.Player2, err)
-					}
-				} else {
-					err := db.IncrementLosses(game.Player1)
-					if err != nil {
-						log.Printf("Error updating losses for %s: %v\n", game.Player1, err)
-					}
-				}
+		}
+		for _, username := range draws {
+			if err := incrementStat(tx, "draws", username); err != nil {
+				return false, fmt.Errorf("updating draws for %s: %w", username, err)
 			}
 		}
-	} else if game.Winner == "draw" {
-		err := db.IncrementDraws(game.Player1)
-		if err != nil {
-			log.Printf("Error updating draws for %s: %v\n", game.Player1, err)
+	}
+
+	if game.Status == "finished" {
+		if err := applyRatings(tx, game); err != nil {
+			return false, fmt.Errorf("updating ratings: %w", err)
 		}
-		if !game.IsBot {
-			err := db.IncrementDraws(game.Player2)
-			if err != nil {
-				log.Printf("Error updating draws for %s: %v\n", game.Player2, err)
-			}
+	}
+
+	for _, event := range events {
+		if err := insertOutboxEvent(tx, event); err != nil {
+			return false, fmt.Errorf("writing %s to outbox: %w", event.EventType, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func insertOutboxEvent(tx *sql.Tx, event GameEvent) error {
+	payload, err := eventschema.Encode(event)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO outbox (id, game_id, event_type, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO NOTHING
+	`, event.EventID, event.GameID, event.EventType, string(payload), time.Now().UTC())
+	return err
+}
+
+// applyRatings moves the players' Elo ratings by the game's result. Both rows
+// exist by now since incrementStat created them.
+func applyRatings(tx *sql.Tx, game *GameState) error {
+	score1, ok := ratingScore(game)
+	if !ok {
+		return nil
+	}
+
+	rating := func(username string) (int, error) {
+		var r int
+		err := tx.QueryRow(`SELECT rating FROM players WHERE username = $1`, username).Scan(&r)
+		return r, err
+	}
+
+	r1, err := rating(game.Player1)
+	if err != nil {
+		return err
+	}
+	r2 := botRating
+	if !game.IsBot {
+		if r2, err = rating(game.Player2); err != nil {
+			return err
 		}
 	}
 
+	r1, r2 = eloRatings(r1, r2, score1)
+	if _, err := tx.Exec(`UPDATE players SET rating = $1 WHERE username = $2`, r1, game.Player1); err != nil {
+		return err
+	}
+	if !game.IsBot {
+		if _, err := tx.Exec(`UPDATE players SET rating = $1 WHERE username = $2`, r2, game.Player2); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (db *Database) IncrementWins(username string) error {
+func (db *Database) GetGame(gameID string) (*GameRecord, error) {
 	query := `
-		INSERT INTO players (username, wins) VALUES ($1, 1)
-		ON CONFLICT (username) DO UPDATE SET
-			wins = wins + 1,
-			updated_at = CURRENT_TIMESTAMP
+		SELECT id, player1, player2, COALESCE(winner, ''), is_bot, status, created_at, updated_at, COALESCE(duration_seconds, 0)
+		FROM games
+		WHERE id = $1
 	`
-	_, err := db.conn.Exec(query, username)
-	return err
+
+	var game GameRecord
+	err := db.conn.QueryRow(query, gameID).Scan(
+		&game.ID, &game.Player1, &game.Player2, &game.Winner, &game.IsBot,
+		&game.Status, &game.CreatedAt, &game.UpdatedAt, &game.DurationSeconds,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &game, nil
 }
 
-func (db *Database) IncrementLosses(username string) error {
+func (db *Database) GetMoves(gameID string) ([]Move, error) {
 	query := `
-		INSERT INTO players (username, losses) VALUES ($1, 1)
-		ON CONFLICT (username) DO UPDATE SET
-			losses = losses + 1,
-			updated_at = CURRENT_TIMESTAMP
+		SELECT move_number, player, col, row_num, played_at
+		FROM moves
+		WHERE game_id = $1
+		ORDER BY move_number
 	`
-	_, err := db.conn.Exec(query, username)
-	return err
+
+	rows, err := db.conn.Query(query, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moves := make([]Move, 0)
+	for rows.Next() {
+		var move Move
+		if err := rows.Scan(&move.Number, &move.Player, &move.Column, &move.Row, &move.PlayedAt); err != nil {
+			return nil, err
+		}
+		moves = append(moves, move)
+	}
+	return moves, rows.Err()
 }
 
-func (db *Database) IncrementDraws(username string) error {
-	query := `
-		INSERT INTO players (username, draws) VALUES ($1, 1)
+// ListPlayerGames returns a page of username's finished games, newest first.
+// The player filter is an OR over player1 and player2 so each side can use its
+// own index.
+func (db *Database) ListPlayerGames(q GameQuery) ([]PlayerGame, error) {
+	args := []interface{}{q.Username}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{
+		"(g.player1 = $1 OR g.player2 = $1)",
+		"g.status = 'finished'",
+	}
+	if q.Opponent != "" {
+		p := arg(q.Opponent)
+		conditions = append(conditions, fmt.Sprintf("(g.player1 = %[1]s OR g.player2 = %[1]s)", p))
+	}
+	switch q.Result {
+	case "win":
+		conditions = append(conditions, "g.winner = $1")
+	case "draw":
+		conditions = append(conditions, "g.winner = 'draw'")
+	case "loss":
+		conditions = append(conditions, "g.winner <> $1 AND g.winner <> 'draw'")
+	}
+	if q.IsBot != nil {
+		conditions = append(conditions, "g.is_bot = "+arg(*q.IsBot))
+	}
+	if !q.From.IsZero() {
+		conditions = append(conditions, "g.created_at >= "+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "g.created_at < "+arg(q.To))
+	}
+	if q.After != nil {
+		at, id := arg(q.After.CreatedAt), arg(q.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(g.created_at < %[1]s OR (g.created_at = %[1]s AND g.id < %[2]s))", at, id))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT g.id, g.player1, g.player2, COALESCE(g.winner, ''), g.is_bot, g.status, g.created_at, g.updated_at, COALESCE(g.duration_seconds, 0),
+			(SELECT COUNT(*) FROM moves m WHERE m.game_id = g.id)
+		FROM games g
+		WHERE %s
+		ORDER BY g.created_at DESC, g.id DESC
+		LIMIT %s
+	`, strings.Join(conditions, " AND "), arg(q.Limit))
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	games := make([]PlayerGame, 0)
+	for rows.Next() {
+		var game GameRecord
+		var moveCount int
+		err := rows.Scan(
+			&game.ID, &game.Player1, &game.Player2, &game.Winner, &game.IsBot,
+			&game.Status, &game.CreatedAt, &game.UpdatedAt, &game.DurationSeconds, &moveCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, newPlayerGame(&game, q.Username, moveCount))
+	}
+	return games, rows.Err()
+}
+
+// incrementStat adds one to a player's wins, losses or draws column, creating
+// the player if needed.
+func incrementStat(tx *sql.Tx, column string, username string) error {
+	switch column {
+	case "wins", "losses", "draws":
+	default:
+		return fmt.Errorf("unknown stat %q", column)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO players (username, %[1]s) VALUES ($1, 1)
 		ON CONFLICT (username) DO UPDATE SET
-			draws = draws + 1,
+			%[1]s = players.%[1]s + 1,
 			updated_at = CURRENT_TIMESTAMP
-	`
-	_, err := db.conn.Exec(query, username)
+	`, column)
+	_, err := tx.Exec(query, username)
 	return err
 }
 
-func (db *Database) GetLeaderboard(limit int) ([]map[string]interface{}, error) {
-	query := `
-		SELECT username, wins, losses, draws, 
-			   CAST(wins AS FLOAT) / NULLIF(wins + losses + draws, 0) as win_rate
-		FROM players
-		WHERE wins + losses + draws > 0
-		ORDER BY wins DESC
-		LIMIT $1
-	`
+// GetLeaderboard ranks players by their results in the query's window. Each
+// game contributes a row per human player, and the all-time board adds each
+// player's archived totals; joining players leaves out deleted accounts.
+// Ranks are numbered before paging so the requested player's entry keeps its
+// true rank.
+func (db *Database) GetLeaderboard(q LeaderboardQuery) (*Leaderboard, error) {
+	args := []interface{}{q.MinGames, q.Offset, q.Offset + q.Limit, q.Player}
+
+	filter := "status = 'finished'"
+	if !q.Since.IsZero() {
+		args = append(args, q.Since)
+		filter += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	player1Filter := filter
+	if q.ExcludeBots {
+		player1Filter += " AND is_bot = false"
+	}
+
+	// Archived games only have all-time totals, so windows leave them out
+	archived := ""
+	if q.Since.IsZero() {
+		archived = `
+			UNION ALL
+			SELECT username, games, wins AS win, draws AS draw
+			FROM archived_results`
+		if q.ExcludeBots {
+			archived = `
+			UNION ALL
+			SELECT username, games - bot_games, wins - bot_wins, draws - bot_draws
+			FROM archived_results
+			WHERE games > bot_games`
+		}
+	}
 
-	rows, err := db.conn.Query(query, limit)
+	query := fmt.Sprintf(`
+		WITH results AS (
+			SELECT player1 AS username,
+				1 AS games,
+				CASE WHEN winner = player1 THEN 1 ELSE 0 END AS win,
+				CASE WHEN winner = 'draw' THEN 1 ELSE 0 END AS draw
+			FROM games
+			WHERE %s
+			UNION ALL
+			SELECT player2 AS username,
+				1 AS games,
+				CASE WHEN winner = player2 THEN 1 ELSE 0 END AS win,
+				CASE WHEN winner = 'draw' THEN 1 ELSE 0 END AS draw
+			FROM games
+			WHERE %s AND is_bot = false%s
+		),
+		totals AS (
+			SELECT r.username,
+				p.rating,
+				SUM(r.games) AS games,
+				SUM(r.win) AS wins,
+				SUM(r.games) - SUM(r.win) - SUM(r.draw) AS losses,
+				SUM(r.draw) AS draws,
+				CAST(SUM(r.win) AS FLOAT) / SUM(r.games) AS win_rate
+			FROM results r
+			JOIN players p ON p.username = r.username
+			GROUP BY r.username, p.rating
+			HAVING SUM(r.games) >= $1
+		),
+		ranked AS (
+			SELECT totals.*,
+				ROW_NUMBER() OVER (ORDER BY %s, username) AS pos,
+				COUNT(*) OVER () AS total
+			FROM totals
+		)
+		SELECT pos, username, rating, games, wins, losses, draws, win_rate, total
+		FROM ranked
+		WHERE (pos > $2 AND pos <= $3) OR username = $4
+		ORDER BY pos
+	`, player1Filter, filter, archived, leaderboardOrder[q.SortBy])
+
+	rows, err := db.conn.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	leaderboard := make([]map[string]interface{}, 0) // Initialize as empty slice instead of nil
+	board := &Leaderboard{
+		Window:  q.Window,
+		SortBy:  q.SortBy,
+		Offset:  q.Offset,
+		Entries: make([]LeaderboardEntry, 0),
+	}
 	for rows.Next() {
-		var username string
-		var wins, losses, draws int
+		var entry LeaderboardEntry
 		var winRate float64
-
-		if err := rows.Scan(&username, &wins, &losses, &draws, &winRate); err != nil {
+		err := rows.Scan(
+			&entry.Rank, &entry.Username, &entry.Rating, &entry.Games,
+			&entry.Wins, &entry.Losses, &entry.Draws, &winRate, &board.Total,
+		)
+		if err != nil {
 			return nil, err
 		}
+		entry.WinRate = percent(winRate)
 
-		leaderboard = append(leaderboard, map[string]interface{}{
-			"username": username,
-			"wins":     wins,
-			"losses":   losses,
-			"draws":    draws,
-			"winRate":  fmt.Sprintf("%.2f%%", winRate*100),
-		})
+		if entry.Username == q.Player {
+			player := entry
+			board.Player = &player
+		}
+		if entry.Rank > q.Offset && entry.Rank <= q.Offset+q.Limit {
+			board.Entries = append(board.Entries, entry)
+		}
 	}
-
-	return leaderboard, nil
+	return board, rows.Err()
 }
 
 func (db *Database) GetPlayerStats(username string) (map[string]interface{}, error) {
 	query := `
-		SELECT username, wins, losses, draws, created_at
+		SELECT username, wins, losses, draws, rating, created_at
 		FROM players
 		WHERE username = $1
 	`
 
 	var username_db string
-	var wins, losses, draws int
+	var wins, losses, draws, rating int
 	var createdAt time.Time
 
-	err := db.conn.QueryRow(query, username).Scan(&username_db, &wins, &losses, &draws, &createdAt)
+	err := db.conn.QueryRow(query, username).Scan(&username_db, &wins, &losses, &draws, &rating, &createdAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return map[string]interface{}{
@@ -250,6 +478,7 @@ func (db *Database) GetPlayerStats(username string) (map[string]interface{}, err
 				"losses":   0,
 				"draws":    0,
 				"winRate":  "0.00%",
+				"rating":   initialRating,
 			}, nil
 		}
 		return nil, err
@@ -267,10 +496,475 @@ func (db *Database) GetPlayerStats(username string) (map[string]interface{}, err
 		"losses":   losses,
 		"draws":    draws,
 		"winRate":  fmt.Sprintf("%.2f%%", winRate),
+		"rating":   rating,
 		"createdAt": createdAt,
 	}, nil
 }
 
+func (db *Database) UnlockAchievements(username string, ids []string, at time.Time) ([]UnlockedAchievement, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	added := make([]UnlockedAchievement, 0)
+	for _, id := range ids {
+		res, err := tx.Exec(`
+			INSERT INTO achievements (username, achievement_id, unlocked_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (username, achievement_id) DO NOTHING
+		`, username, id, at)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n > 0 {
+			added = append(added, UnlockedAchievement{ID: id, UnlockedAt: at})
+		}
+	}
+	return added, tx.Commit()
+}
+
+func (db *Database) GetAchievements(username string) ([]UnlockedAchievement, error) {
+	rows, err := db.conn.Query(`
+		SELECT achievement_id, unlocked_at
+		FROM achievements
+		WHERE username = $1
+		ORDER BY unlocked_at, achievement_id
+	`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unlocked := make([]UnlockedAchievement, 0)
+	for rows.Next() {
+		var u UnlockedAchievement
+		if err := rows.Scan(&u.ID, &u.UnlockedAt); err != nil {
+			return nil, err
+		}
+		unlocked = append(unlocked, u)
+	}
+	return unlocked, rows.Err()
+}
+
+func (db *Database) GetProfile(username string) (*Profile, error) {
+	query := `
+		SELECT COALESCE(display_name, ''), COALESCE(avatar, ''), COALESCE(country, ''),
+			COALESCE(bot_difficulty, ''), COALESCE(board_variant, '')
+		FROM players
+		WHERE username = $1
+	`
+
+	profile := &Profile{Username: username}
+	err := db.conn.QueryRow(query, username).Scan(
+		&profile.DisplayName, &profile.Avatar, &profile.Country,
+		&profile.Preferences.BotDifficulty, &profile.Preferences.BoardVariant,
+	)
+	if err == sql.ErrNoRows {
+		return defaultProfile(username), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return profile.withDefaults(), nil
+}
+
+func (db *Database) SaveProfile(profile *Profile) error {
+	query := `
+		INSERT INTO players (username, display_name, avatar, country, bot_difficulty, board_variant)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (username) DO UPDATE SET
+			display_name = $2,
+			avatar = $3,
+			country = $4,
+			bot_difficulty = $5,
+			board_variant = $6,
+			updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := db.conn.Exec(query,
+		profile.Username,
+		profile.DisplayName,
+		profile.Avatar,
+		profile.Country,
+		profile.Preferences.BotDifficulty,
+		profile.Preferences.BoardVariant,
+	)
+	return err
+}
+
+func (db *Database) ClaimPlayerToken(username string, tokenHash string) (bool, error) {
+	res, err := db.conn.Exec(`
+		INSERT INTO player_tokens (username, token_hash)
+		VALUES ($1, $2)
+		ON CONFLICT (username) DO NOTHING
+	`, username, tokenHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (db *Database) PlayerTokenHash(username string) (string, error) {
+	var hash string
+	err := db.conn.QueryRow(`SELECT token_hash FROM player_tokens WHERE username = $1`, username).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return hash, err
+}
+
+func (db *Database) ListGamesBefore(before time.Time, limit int) ([]ArchivedGame, error) {
+	query := `
+		SELECT id, player1, player2, COALESCE(winner, ''), is_bot, status, created_at, updated_at, COALESCE(duration_seconds, 0)
+		FROM games
+		WHERE status = 'finished' AND created_at < $1
+		ORDER BY created_at, id
+		LIMIT $2
+	`
+
+	rows, err := db.conn.Query(query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	games := make([]ArchivedGame, 0)
+	for rows.Next() {
+		var g ArchivedGame
+		err := rows.Scan(
+			&g.Game.ID, &g.Game.Player1, &g.Game.Player2, &g.Game.Winner, &g.Game.IsBot,
+			&g.Game.Status, &g.Game.CreatedAt, &g.Game.UpdatedAt, &g.Game.DurationSeconds,
+		)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range games {
+		if games[i].Moves, err = db.GetMoves(games[i].Game.ID); err != nil {
+			return nil, err
+		}
+	}
+	return games, nil
+}
+
+func (db *Database) DeleteArchivedGames(games []ArchivedGame) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, g := range games {
+		game := &g.Game
+		res, err := tx.Exec(`DELETE FROM games WHERE id = $1`, game.ID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue // archived before
+		}
+		if _, err := tx.Exec(`DELETE FROM moves WHERE game_id = $1`, game.ID); err != nil {
+			return err
+		}
+
+		if err := addArchivedResult(tx, game, game.Player1); err != nil {
+			return err
+		}
+		if !game.IsBot {
+			if err := addArchivedResult(tx, game, game.Player2); err != nil {
+				return err
+			}
+		}
+		if err := addArchivedHeadToHead(tx, game, len(g.Moves)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// addArchivedResult counts game in username's archived results, keeping bot
+// games apart so the leaderboard can still leave them out.
+func addArchivedResult(tx *sql.Tx, game *GameRecord, username string) error {
+	win, draw := 0, 0
+	switch gameResult(game.Winner, username) {
+	case "win":
+		win = 1
+	case "draw":
+		draw = 1
+	}
+	bot, botWin, botDraw := 0, 0, 0
+	if game.IsBot {
+		bot, botWin, botDraw = 1, win, draw
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO archived_results (username, games, wins, draws, bot_games, bot_wins, bot_draws)
+		VALUES ($1, 1, $2, $3, $4, $5, $6)
+		ON CONFLICT (username) DO UPDATE SET
+			games = archived_results.games + 1,
+			wins = archived_results.wins + excluded.wins,
+			draws = archived_results.draws + excluded.draws,
+			bot_games = archived_results.bot_games + excluded.bot_games,
+			bot_wins = archived_results.bot_wins + excluded.bot_wins,
+			bot_draws = archived_results.bot_draws + excluded.bot_draws
+	`, username, win, draw, bot, botWin, botDraw)
+	return err
+}
+
+// addArchivedHeadToHead folds game into its players' archived head-to-head
+// totals. A pair has one row, keyed in whichever order it was first seen.
+func addArchivedHeadToHead(tx *sql.Tx, game *GameRecord, moveCount int) error {
+	a, b := game.Player1, game.Player2
+	t, playerA, err := archivedHeadToHead(tx, a, b)
+	if err != nil {
+		return err
+	}
+	t.add(newPlayerGame(game, a, moveCount))
+
+	if playerA == "" {
+		_, err = tx.Exec(`
+			INSERT INTO archived_head_to_head (player_a, player_b, games, wins_a, wins_b, draws,
+				streak_a, streak_b, longest_a, longest_b, duration_seconds, moves,
+				first_mover_wins_a, first_mover_wins_b)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		`, a, b, t.Games, t.WinsA, t.WinsB, t.Draws, t.StreakA, t.StreakB, t.LongestA, t.LongestB,
+			t.DurationSeconds, t.Moves, t.FirstMoverWinsA, t.FirstMoverWinsB)
+		return err
+	}
+
+	if playerA != a {
+		a, b, t = b, a, t.swap()
+	}
+	_, err = tx.Exec(`
+		UPDATE archived_head_to_head SET games = $3, wins_a = $4, wins_b = $5, draws = $6,
+			streak_a = $7, streak_b = $8, longest_a = $9, longest_b = $10,
+			duration_seconds = $11, moves = $12, first_mover_wins_a = $13, first_mover_wins_b = $14
+		WHERE player_a = $1 AND player_b = $2
+	`, a, b, t.Games, t.WinsA, t.WinsB, t.Draws, t.StreakA, t.StreakB, t.LongestA, t.LongestB,
+		t.DurationSeconds, t.Moves, t.FirstMoverWinsA, t.FirstMoverWinsB)
+	return err
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// archivedHeadToHead reads the archived totals between a and b from a's side,
+// along with the player_a of their row, which is "" if they have none.
+func archivedHeadToHead(q queryRower, a string, b string) (HeadToHeadTotals, string, error) {
+	var t HeadToHeadTotals
+	var playerA string
+	err := q.QueryRow(`
+		SELECT player_a, games, wins_a, wins_b, draws, streak_a, streak_b, longest_a, longest_b,
+			duration_seconds, moves, first_mover_wins_a, first_mover_wins_b
+		FROM archived_head_to_head
+		WHERE (player_a = $1 AND player_b = $2) OR (player_a = $2 AND player_b = $1)
+	`, a, b).Scan(
+		&playerA, &t.Games, &t.WinsA, &t.WinsB, &t.Draws, &t.StreakA, &t.StreakB, &t.LongestA, &t.LongestB,
+		&t.DurationSeconds, &t.Moves, &t.FirstMoverWinsA, &t.FirstMoverWinsB,
+	)
+	if err == sql.ErrNoRows {
+		return HeadToHeadTotals{}, "", nil
+	}
+	if err != nil {
+		return t, "", err
+	}
+	if playerA != a {
+		t = t.swap()
+	}
+	return t, playerA, nil
+}
+
+func (db *Database) ArchivedHeadToHead(a string, b string) (HeadToHeadTotals, error) {
+	t, _, err := archivedHeadToHead(db.conn, a, b)
+	return t, err
+}
+
+// AnonymizePlayer rewrites the player's games and outbox events and removes
+// their profile, stats and achievements in one transaction. Opponents' rows
+// are untouched, so their counters and ratings stay as they were.
+func (db *Database) AnonymizePlayer(username string, alias string) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	found := false
+	statements := []string{
+		`UPDATE games SET player1 = $2 WHERE player1 = $1`,
+		`UPDATE games SET player2 = $2 WHERE player2 = $1`,
+		`UPDATE games SET winner = $2 WHERE winner = $1`,
+	}
+	for _, stmt := range statements {
+		res, err := tx.Exec(stmt, username, alias)
+		if err != nil {
+			return false, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			found = true
+		}
+	}
+
+	// Opponents keep their archived record against the player; the player's
+	// own archived results go with their stats
+	for _, stmt := range []string{
+		`UPDATE archived_head_to_head SET player_a = $2 WHERE player_a = $1`,
+		`UPDATE archived_head_to_head SET player_b = $2 WHERE player_b = $1`,
+	} {
+		res, err := tx.Exec(stmt, username, alias)
+		if err != nil {
+			return false, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			found = true
+		}
+	}
+
+	n, err := anonymizeOutbox(tx, username, alias)
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		found = true
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM achievements WHERE username = $1`,
+		`DELETE FROM player_tokens WHERE username = $1`,
+		`DELETE FROM archived_results WHERE username = $1`,
+	} {
+		if _, err := tx.Exec(stmt, username); err != nil {
+			return false, err
+		}
+	}
+	res, err := tx.Exec(`DELETE FROM players WHERE username = $1`, username)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		found = true
+	}
+
+	return found, tx.Commit()
+}
+
+// anonymizeOutbox rewrites the outbox events that name username, delivered
+// or not, and returns how many changed.
+func anonymizeOutbox(tx *sql.Tx, username string, alias string) (int, error) {
+	quoted, err := json.Marshal(username)
+	if err != nil {
+		return 0, err
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	rows, err := tx.Query(
+		`SELECT id, payload FROM outbox WHERE payload LIKE $1 ESCAPE '\'`,
+		"%"+escaper.Replace(string(quoted))+"%",
+	)
+	if err != nil {
+		return 0, err
+	}
+	var events []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		var payload string
+		if err := rows.Scan(&e.ID, &payload); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if e.Event, err = eventschema.Decode([]byte(payload)); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("outbox event %s: %w", e.ID, err)
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, e := range events {
+		if !anonymizeEvent(&e.Event, username, alias) {
+			continue
+		}
+		payload, err := eventschema.Encode(e.Event)
+		if err != nil {
+			return changed, err
+		}
+		if _, err := tx.Exec(`UPDATE outbox SET payload = $1 WHERE id = $2`, string(payload), e.ID); err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+func (db *Database) PendingEvents(limit int) ([]OutboxEvent, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, payload
+		FROM outbox
+		WHERE delivered_at IS NULL
+		ORDER BY created_at, id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := make([]OutboxEvent, 0)
+	for rows.Next() {
+		var e OutboxEvent
+		var payload string
+		if err := rows.Scan(&e.ID, &payload); err != nil {
+			return nil, err
+		}
+		// Rows written by an older release are upcast like any other event
+		if e.Event, err = eventschema.Decode([]byte(payload)); err != nil {
+			return nil, fmt.Errorf("outbox event %s: %w", e.ID, err)
+		}
+		pending = append(pending, e)
+	}
+	return pending, rows.Err()
+}
+
+func (db *Database) MarkDelivered(ids []string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, id := range ids {
+		if _, err := tx.Exec(`UPDATE outbox SET delivered_at = $1 WHERE id = $2`, now, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *Database) DeleteDeliveredEvents(before time.Time) (int64, error) {
+	res, err := db.conn.Exec(`DELETE FROM outbox WHERE delivered_at IS NOT NULL AND delivered_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 func (db *Database) Close() error {
 	return db.conn.Close()
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"eventschema"
+)
+
+// EventSink receives the game events consumed by the analytics service.
+// Publish may be called from several goroutines.
+type EventSink interface {
+	Publish(event GameEvent) error
+	Close() error
+}
+
+// EventSinkConfig selects the EventSink implementation.
+type EventSinkConfig struct {
+	Kind        string // "kafka", "file", "memory" or "none"
+	KafkaBroker string
+	KafkaTopic  string
+	FilePath    string
+}
+
+// LoadEventSinkConfig reads EVENT_SINK, defaulting to kafka when
+// KAFKA_BROKER is set and to none otherwise.
+func LoadEventSinkConfig() EventSinkConfig {
+	cfg := EventSinkConfig{
+		Kind:        os.Getenv("EVENT_SINK"),
+		KafkaBroker: os.Getenv("KAFKA_BROKER"),
+		KafkaTopic:  os.Getenv("KAFKA_TOPIC"),
+		FilePath:    os.Getenv("EVENTS_FILE"),
+	}
+	if cfg.Kind == "" {
+		cfg.Kind = "none"
+		if cfg.KafkaBroker != "" {
+			cfg.Kind = "kafka"
+		}
+	}
+	if cfg.KafkaTopic == "" {
+		cfg.KafkaTopic = "game_events"
+	}
+	if cfg.FilePath == "" {
+		cfg.FilePath = "events.ndjson"
+	}
+	return cfg
+}
+
+func OpenEventSink(cfg EventSinkConfig) (EventSink, error) {
+	switch cfg.Kind {
+	case "kafka":
+		if cfg.KafkaBroker == "" {
+			return nil, fmt.Errorf("KAFKA_BROKER not set")
+		}
+		return NewKafkaProducer(cfg.KafkaBroker, cfg.KafkaTopic)
+	case "file":
+		return NewFileSink(cfg.FilePath)
+	case "memory":
+		return NewMemorySink(), nil
+	case "none":
+		return NoopSink{}, nil
+	default:
+		return nil, fmt.Errorf("unknown EVENT_SINK %q (want kafka, file, memory or none)", cfg.Kind)
+	}
+}
+
+// NoopSink discards every event.
+type NoopSink struct{}
+
+func (NoopSink) Publish(event GameEvent) error { return nil }
+func (NoopSink) Close() error                  { return nil }
+
+// MemorySink keeps events in memory so tests can inspect them.
+type MemorySink struct {
+	mu     sync.Mutex
+	events []GameEvent
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Publish(event GameEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+// Events returns a copy of everything published so far.
+func (s *MemorySink) Events() []GameEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]GameEvent(nil), s.events...)
+}
+
+func (s *MemorySink) Close() error {
+	return nil
+}
+
+// FileSink appends events to a file as newline-delimited JSON.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Publish(event GameEvent) error {
+	data, err := eventschema.Encode(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
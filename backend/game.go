@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 )
 
 const (
@@ -28,6 +29,13 @@ type GameState struct {
 	CreatedAt string
 	UpdatedAt string
 	IsBot     bool
+	BotDifficulty string // "easy", "medium" or "hard" in bot games
+	Seq       int64 // sequence number of the last event sent for this game
+	Forfeit   bool  // ended because a player stayed disconnected
+	Moves     []Move
+
+	events   []*Message             // every event sent for this game, for resync
+	forfeits map[string]*time.Timer // pending disconnect forfeits by player; guarded by Hub.mu
 }
 
 func NewBoard() *Board {
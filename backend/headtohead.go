@@ -0,0 +1,176 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Number of most recent games included in a head-to-head record.
+const headToHeadRecentGames = 10
+
+// HeadToHead is the full record between two players. Games, wins and streaks
+// are counted over every finished game they played against each other.
+type HeadToHead struct {
+	PlayerA                string           `json:"playerA"`
+	PlayerB                string           `json:"playerB"`
+	Games                  int              `json:"games"`
+	Wins                   map[string]int   `json:"wins"`
+	Draws                  int              `json:"draws"`
+	CurrentStreak          Streak           `json:"currentStreak"`
+	LongestStreak          map[string]int   `json:"longestStreak"`
+	AverageDurationSeconds float64          `json:"averageDurationSeconds"`
+	AverageMoves           float64          `json:"averageMoves"`
+	FirstMover             FirstMoverRecord `json:"firstMover"`
+	RecentGames            []PlayerGame     `json:"recentGames"`
+}
+
+// Streak is a run of consecutive wins. A draw ends any streak.
+type Streak struct {
+	Player string `json:"player,omitempty"`
+	Length int    `json:"length"`
+}
+
+// FirstMoverRecord shows how the player who dropped the first disc fared, in
+// total and per player.
+type FirstMoverRecord struct {
+	Wins    int            `json:"wins"`
+	Losses  int            `json:"losses"`
+	Draws   int            `json:"draws"`
+	WinRate float64        `json:"winRate"`
+	WinsBy  map[string]int `json:"winsBy"`
+}
+
+// HeadToHeadTotals is a head-to-head record as running totals from A's side.
+// Retention folds archived games into these, so a record still counts games
+// whose rows are gone.
+type HeadToHeadTotals struct {
+	Games            int
+	WinsA, WinsB     int
+	Draws            int
+	StreakA, StreakB int // the current streak; at most one is non-zero
+	LongestA         int
+	LongestB         int
+	DurationSeconds  int
+	Moves            int
+	FirstMoverWinsA  int
+	FirstMoverWinsB  int
+}
+
+// add counts a game, from A's side, played after every game counted so far.
+func (t *HeadToHeadTotals) add(game PlayerGame) {
+	t.Games++
+	t.DurationSeconds += game.DurationSeconds
+	t.Moves += game.MoveCount
+
+	switch game.Result {
+	case "win":
+		t.WinsA++
+		t.StreakA, t.StreakB = t.StreakA+1, 0
+		if t.StreakA > t.LongestA {
+			t.LongestA = t.StreakA
+		}
+		if game.MovedFirst {
+			t.FirstMoverWinsA++
+		}
+	case "loss":
+		t.WinsB++
+		t.StreakA, t.StreakB = 0, t.StreakB+1
+		if t.StreakB > t.LongestB {
+			t.LongestB = t.StreakB
+		}
+		if !game.MovedFirst {
+			t.FirstMoverWinsB++
+		}
+	default:
+		t.Draws++
+		t.StreakA, t.StreakB = 0, 0
+	}
+}
+
+// swap returns the same totals from B's side.
+func (t HeadToHeadTotals) swap() HeadToHeadTotals {
+	t.WinsA, t.WinsB = t.WinsB, t.WinsA
+	t.StreakA, t.StreakB = t.StreakB, t.StreakA
+	t.LongestA, t.LongestB = t.LongestB, t.LongestA
+	t.FirstMoverWinsA, t.FirstMoverWinsB = t.FirstMoverWinsB, t.FirstMoverWinsA
+	return t
+}
+
+// BuildHeadToHead computes the record from a's side of the archived totals
+// followed by their games with b, ordered newest first as ListPlayerGames
+// returns them. Archiving removes the oldest games, so the totals always come
+// before the games.
+func BuildHeadToHead(a string, b string, archived HeadToHeadTotals, games []PlayerGame) *HeadToHead {
+	t := archived
+	for i := len(games) - 1; i >= 0; i-- {
+		t.add(games[i])
+	}
+
+	firstMoverWins := t.FirstMoverWinsA + t.FirstMoverWinsB
+	h := &HeadToHead{
+		PlayerA:       a,
+		PlayerB:       b,
+		Games:         t.Games,
+		Wins:          map[string]int{a: t.WinsA, b: t.WinsB},
+		Draws:         t.Draws,
+		LongestStreak: map[string]int{a: t.LongestA, b: t.LongestB},
+		FirstMover: FirstMoverRecord{
+			Wins:   firstMoverWins,
+			Losses: t.WinsA + t.WinsB - firstMoverWins,
+			Draws:  t.Draws,
+			WinsBy: map[string]int{a: t.FirstMoverWinsA, b: t.FirstMoverWinsB},
+		},
+		RecentGames: games,
+	}
+	if len(games) > headToHeadRecentGames {
+		h.RecentGames = games[:headToHeadRecentGames]
+	}
+
+	switch {
+	case t.StreakA > 0:
+		h.CurrentStreak = Streak{Player: a, Length: t.StreakA}
+	case t.StreakB > 0:
+		h.CurrentStreak = Streak{Player: b, Length: t.StreakB}
+	}
+
+	if t.Games > 0 {
+		h.AverageDurationSeconds = float64(t.DurationSeconds) / float64(t.Games)
+		h.AverageMoves = float64(t.Moves) / float64(t.Games)
+		h.FirstMover.WinRate = float64(firstMoverWins) / float64(t.Games)
+	}
+	return h
+}
+
+func (s *Server) getHeadToHead(c *gin.Context) {
+	a, b := c.Param("a"), c.Param("b")
+	if a == b {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pick two different players"})
+		return
+	}
+
+	archived, err := s.db.ArchivedHeadToHead(a, b)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch games"})
+		return
+	}
+
+	// Page through every game between the two, newest first
+	games := make([]PlayerGame, 0)
+	q := GameQuery{Username: a, Opponent: b, Limit: maxHistoryLimit}
+	for {
+		page, err := s.db.ListPlayerGames(q)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch games"})
+			return
+		}
+		games = append(games, page...)
+		if len(page) < q.Limit {
+			break
+		}
+		last := page[len(page)-1]
+		q.After = &GameCursor{CreatedAt: last.PlayedAt, ID: last.ID}
+	}
+
+	c.JSON(http.StatusOK, BuildHeadToHead(a, b, archived, games))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// GameQuery selects a page of one player's finished games, newest first.
+// A plain to=YYYY-MM-DD date in the query string sets To to the end of that
+// day, so the day is included, as in the analytics API.
+type GameQuery struct {
+	Username string
+	Opponent string      // only games against this player
+	Result   string      // "win", "loss" or "draw" from Username's side
+	IsBot    *bool       // only bot games (true) or only human games (false)
+	From     time.Time   // games started at or after From
+	To       time.Time   // games started before To
+	After    *GameCursor // continue after this game
+	Limit    int
+}
+
+// GameCursor is the position of the last game on a page. Games are ordered by
+// start time then ID, so the pair identifies a row even when times collide.
+type GameCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// Encode renders the cursor as the opaque token clients pass back.
+func (c GameCursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeGameCursor(token string) (*GameCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &GameCursor{CreatedAt: time.Unix(0, nanos).UTC(), ID: parts[1]}, nil
+}
+
+// PlayerGame is one entry in a player's game history, seen from their side.
+type PlayerGame struct {
+	ID              string    `json:"id"`
+	Opponent        string    `json:"opponent"`
+	Result          string    `json:"result"`
+	IsBot           bool      `json:"isBot"`
+	MovedFirst      bool      `json:"movedFirst"`
+	DurationSeconds int       `json:"durationSeconds"`
+	MoveCount       int       `json:"moveCount"`
+	PlayedAt        time.Time `json:"playedAt"`
+	ReplayURL       string    `json:"replayUrl"`
+}
+
+// newPlayerGame builds username's view of a saved game.
+func newPlayerGame(game *GameRecord, username string, moveCount int) PlayerGame {
+	opponent := game.Player2
+	if game.Player2 == username {
+		opponent = game.Player1
+	}
+
+	return PlayerGame{
+		ID:              game.ID,
+		Opponent:        opponent,
+		Result:          gameResult(game.Winner, username),
+		IsBot:           game.IsBot,
+		MovedFirst:      game.Player1 == username, // Player1 goes first
+		DurationSeconds: game.DurationSeconds,
+		MoveCount:       moveCount,
+		PlayedAt:        game.CreatedAt,
+		ReplayURL:       "/api/game/" + game.ID,
+	}
+}
+
+// gameResult is the outcome of a finished game for username.
+func gameResult(winner string, username string) string {
+	switch winner {
+	case "draw":
+		return "draw"
+	case username:
+		return "win"
+	default:
+		return "loss"
+	}
+}
+
+// parseGameQuery reads the history filters from the query string.
+func parseGameQuery(c *gin.Context) (GameQuery, error) {
+	q := GameQuery{
+		Username: c.Param("username"),
+		Opponent: c.Query("opponent"),
+		Result:   c.Query("result"),
+		Limit:    defaultHistoryLimit,
+	}
+
+	switch q.Result {
+	case "", "win", "loss", "draw":
+	default:
+		return q, fmt.Errorf("result must be win, loss or draw")
+	}
+
+	if v := c.Query("bot"); v != "" {
+		isBot, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, fmt.Errorf("bot must be true or false")
+		}
+		q.IsBot = &isBot
+	}
+
+	var err error
+	if q.From, err = parseDateParam(c.Query("from"), false); err != nil {
+		return q, fmt.Errorf("from: %w", err)
+	}
+	if q.To, err = parseDateParam(c.Query("to"), true); err != nil {
+		return q, fmt.Errorf("to: %w", err)
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return q, fmt.Errorf("limit must be a positive number")
+		}
+		if limit > maxHistoryLimit {
+			limit = maxHistoryLimit
+		}
+		q.Limit = limit
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		if q.After, err = DecodeGameCursor(v); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// parseDateParam accepts an RFC 3339 timestamp or a plain YYYY-MM-DD date.
+// With endOfRange, a plain date means the end of that day.
+func parseDateParam(v string, endOfRange bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("want RFC 3339 or YYYY-MM-DD, got %q", v)
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func (s *Server) getPlayerGames(c *gin.Context) {
+	q, err := parseGameQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Fetch one extra game to learn whether there is another page
+	limit := q.Limit
+	q.Limit++
+	games, err := s.db.ListPlayerGames(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch games"})
+		return
+	}
+
+	response := gin.H{"games": games}
+	if len(games) > limit {
+		games = games[:limit]
+		last := games[limit-1]
+		response["games"] = games
+		response["nextCursor"] = GameCursor{CreatedAt: last.PlayedAt, ID: last.ID}.Encode()
+	}
+	c.JSON(http.StatusOK, response)
+}
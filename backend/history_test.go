@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestGameCursorRoundTrip(t *testing.T) {
+	tests := []GameCursor{
+		{CreatedAt: time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC), ID: "g1"},
+		{CreatedAt: time.Date(2026, 3, 14, 10, 0, 0, 123456789, time.UTC), ID: "0c9a7f2e-5b1d-4c1e-9f0a-3d2b1c4e5f6a"},
+		{CreatedAt: time.Date(2026, 3, 14, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600)), ID: "with|pipe"},
+		{CreatedAt: time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC), ID: ""},
+	}
+	for _, c := range tests {
+		got, err := DecodeGameCursor(c.Encode())
+		if err != nil {
+			t.Fatalf("decoding %+v: %v", c, err)
+		}
+		if !got.CreatedAt.Equal(c.CreatedAt) || got.ID != c.ID || got.CreatedAt.Location() != time.UTC {
+			t.Fatalf("round trip of %+v = %+v", c, got)
+		}
+	}
+}
+
+func TestDecodeGameCursorInvalid(t *testing.T) {
+	for _, token := range []string{
+		"",
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("no separator")),
+		base64.RawURLEncoding.EncodeToString([]byte("yesterday|g1")),
+		base64.StdEncoding.EncodeToString([]byte("1773482400000000000|g1")),
+	} {
+		if c, err := DecodeGameCursor(token); err == nil {
+			t.Fatalf("DecodeGameCursor(%q) = %+v, want an error", token, c)
+		}
+	}
+}
+
+func TestParseDateParam(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		endOfRange bool
+		want       time.Time
+		wantErr    bool
+	}{
+		{name: "empty", value: "", want: time.Time{}},
+		{name: "empty end", value: "", endOfRange: true, want: time.Time{}},
+		{name: "date", value: "2026-03-14", want: time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{name: "date as end includes the day", value: "2026-03-14", endOfRange: true, want: time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "date at end of year", value: "2026-12-31", endOfRange: true, want: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "RFC 3339", value: "2026-03-14T09:30:00Z", want: time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)},
+		{name: "RFC 3339 as end is exact", value: "2026-03-14T09:30:00Z", endOfRange: true, want: time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC)},
+		{name: "RFC 3339 with offset", value: "2026-03-14T09:30:00+02:00", want: time.Date(2026, 3, 14, 7, 30, 0, 0, time.UTC)},
+		{name: "garbage", value: "yesterday", wantErr: true},
+		{name: "impossible date", value: "2026-02-30", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDateParam(tt.value, tt.endOfRange)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseDateParam(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Fatalf("parseDateParam(%q, %v) = %v, want %v", tt.value, tt.endOfRange, got, tt.want)
+			}
+		})
+	}
+}
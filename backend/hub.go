@@ -1,13 +1,20 @@
 package main
 
 import (
+	"encoding/json"
 	"log"
 	"sync"
 	"time"
 
+	"eventschema"
+
 	"github.com/google/uuid"
 )
 
+// reconnectWindow is how long a disconnected player has to rejoin their game
+// before forfeiting it.
+const reconnectWindow = 30 * time.Second
+
 type Hub struct {
 	clients      map[*Client]bool
 	broadcast    chan interface{}
@@ -20,29 +27,71 @@ type Hub struct {
 }
 
 type Client struct {
+	id        string
 	hub       *Hub
-	conn      *WSConnection
+	conn      ClientConn
 	send      chan interface{}
 	username  string
 	gameID    string
 	closedAt  time.Time
+	evictOnce sync.Once
+
+	sendMu     sync.Mutex
+	sendClosed bool // send is closed; guarded by sendMu
+
+	deltaMoves   bool   // send game_move without the board once snapshotted
+	snapshotGame string // game the client holds a board snapshot of; writer only
+}
+
+// ClientConn is the transport behind a Client. The hub only ever closes it;
+// delivering queued messages is up to the transport.
+type ClientConn interface {
+	Close() error
+}
+
+// streamConn is a transport that messages are pushed to as they're queued.
+type streamConn interface {
+	ClientConn
+	WriteMessage(msg interface{}) error
+	WritePing() error
+	WriteClose() error
 }
 
 type MatchmakeRequest struct {
-	Username  string
-	Timestamp time.Time
-	Client    *Client
+	Username      string
+	Timestamp     time.Time
+	Client        *Client
+	BotDifficulty string // used if the player ends up against the bot
 }
 
 type Message struct {
-	Type    string      `json:"type"`
-	Payload interface{} `json:"payload"`
+	Type      string      `json:"type"`
+	RequestID string      `json:"requestId,omitempty"`
+	Seq       int64       `json:"seq,omitempty"`
+	Payload   interface{} `json:"payload"`
+}
+
+// AckMessage confirms that the request identified by the enclosing message's
+// RequestID was accepted. Seq is the game sequence number the request produced
+// (or the latest one, for requests that don't produce an event).
+type AckMessage struct {
+	Type string `json:"type"`
+	Seq  int64  `json:"seq,omitempty"`
+}
+
+type ErrorMessage struct {
+	Message string `json:"message"`
 }
 
 type GameMoveMessage struct {
 	Column int `json:"column"`
 }
 
+type ResyncMessage struct {
+	GameID  string `json:"gameId"`
+	FromSeq int64  `json:"fromSeq"`
+}
+
 type GameStartMessage struct {
 	GameID   string `json:"gameId"`
 	Player1  string `json:"player1"`
@@ -56,10 +105,24 @@ type GameMoveEventMessage struct {
 	Column       int    `json:"column"`
 	Row          int    `json:"row"`
 	Player       int    `json:"player"`
-	Board        [ROWS][COLS]int `json:"board"`
+	Board        *[ROWS][COLS]int `json:"board,omitempty"` // omitted for delta clients
 	CurrentPlayer int    `json:"currentPlayer"`
 }
 
+// GameSnapshotMessage is the full state of a game as of Seq, sent on request
+// so a client can apply move deltas from there.
+type GameSnapshotMessage struct {
+	GameID        string          `json:"gameId"`
+	Player1       string          `json:"player1"`
+	Player2       string          `json:"player2"`
+	IsBot         bool            `json:"isBot"`
+	Board         [ROWS][COLS]int `json:"board"`
+	CurrentPlayer int             `json:"currentPlayer"`
+	Status        string          `json:"status"`
+	Winner        string          `json:"winner,omitempty"`
+	Seq           int64           `json:"seq"`
+}
+
 type GameResultMessage struct {
 	GameID string `json:"gameId"`
 	Winner string `json:"winner"` // "player1", "player2", "draw"
@@ -67,6 +130,15 @@ type GameResultMessage struct {
 	WinCol int    `json:"winCol,omitempty"`
 }
 
+func newClient(hub *Hub, conn ClientConn) *Client {
+	return &Client{
+		id:   uuid.New().String(),
+		hub:  hub,
+		conn: conn,
+		send: make(chan interface{}, 256),
+	}
+}
+
 func NewHub(gameManager *GameManager) *Hub {
 	return &Hub{
 		clients:     make(map[*Client]bool),
@@ -95,7 +167,15 @@ func (h *Hub) Run() {
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
-				close(client.send)
+				client.closeSend()
+			}
+			// A client that drops out of the queue must not be matched later
+			if req, ok := h.matchmaking[client.username]; ok && req.Client == client {
+				delete(h.matchmaking, client.username)
+				event := newPlayerEvent(eventschema.MatchmakingLeft, client.username)
+				event.Reason = "disconnected"
+				event.Duration = int(time.Since(req.Timestamp).Seconds())
+				h.gameManager.Publish(event)
 			}
 			h.mu.Unlock()
 			log.Printf("Client unregistered: %s\n", client.username)
@@ -108,11 +188,7 @@ func (h *Hub) Run() {
 		case message := <-h.broadcast:
 			h.mu.RLock()
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					// Client's send channel is full, skip
-				}
+				client.trySend(message)
 			}
 			h.mu.RUnlock()
 
@@ -134,15 +210,72 @@ func (h *Hub) BroadcastMessage(msg interface{}) {
 	h.broadcast <- msg
 }
 
+// HandleMessage dispatches a message from a client, whichever transport it
+// arrived on.
+func (h *Hub) HandleMessage(client *Client, msg *InboundMessage) {
+	log.Printf("Received message type: %s from %s\n", msg.Type, client.username)
+
+	switch msg.Type {
+	case "register":
+		var registerMsg struct {
+			Username string `json:"username"`
+		}
+		json.Unmarshal(msg.Payload, &registerMsg)
+		client.username = registerMsg.Username
+		h.RequestMatchmaking(registerMsg.Username, client)
+		client.sendAck(msg.RequestID, msg.Type, 0)
+		if token := h.gameManager.IssuePlayerToken(registerMsg.Username); token != "" {
+			client.trySend(&Message{
+				Type:    "player_token",
+				Payload: PlayerTokenMessage{Username: registerMsg.Username, Token: token},
+			})
+		}
+		log.Printf("Player registered: %s\n", registerMsg.Username)
+
+	case "game_move":
+		var moveMsg GameMoveMessage
+		json.Unmarshal(msg.Payload, &moveMsg)
+		h.HandleGameMove(client, msg.RequestID, moveMsg.Column)
+
+	case "rejoin":
+		var rejoinMsg struct {
+			GameID string `json:"gameId"`
+		}
+		json.Unmarshal(msg.Payload, &rejoinMsg)
+		h.Rejoin(client, msg.RequestID, rejoinMsg.GameID)
+
+	case "snapshot":
+		var snapshotMsg struct {
+			GameID string `json:"gameId"`
+		}
+		json.Unmarshal(msg.Payload, &snapshotMsg)
+		h.Snapshot(client, msg.RequestID, snapshotMsg.GameID)
+
+	case "resync":
+		var resyncMsg ResyncMessage
+		json.Unmarshal(msg.Payload, &resyncMsg)
+		h.Resync(client, msg.RequestID, resyncMsg.GameID, resyncMsg.FromSeq)
+
+	default:
+		log.Printf("Unknown message type: %s\n", msg.Type)
+		client.sendError(msg.RequestID, "Unknown message type: "+msg.Type)
+	}
+}
+
 func (h *Hub) RequestMatchmaking(username string, client *Client) {
+	// Looked up now so matchmaking never waits on the store
+	botDifficulty := h.gameManager.BotDifficulty(username)
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	h.matchmaking[username] = &MatchmakeRequest{
-		Username:  username,
-		Timestamp: time.Now(),
-		Client:    client,
+		Username:      username,
+		Timestamp:     time.Now(),
+		Client:        client,
+		BotDifficulty: botDifficulty,
 	}
+	h.gameManager.Publish(newPlayerEvent(eventschema.MatchmakingJoined, username))
 
 	log.Printf("Matchmaking request from %s\n", username)
 }
@@ -161,7 +294,11 @@ func (h *Hub) processMatchmaking() {
 		} else {
 			// Timeout - pair with bot
 			if req.Client != nil && req.Client.send != nil {
-				h.createGameWithBot(username, req.Client)
+				gameState := h.createGameWithBot(username, req.Client, req.BotDifficulty)
+				event := newGameEvent(eventschema.BotFallback, gameState, 0)
+				event.EventID = eventschema.StableID(gameState.ID, eventschema.BotFallback)
+				event.Duration = int(now.Sub(req.Timestamp).Seconds())
+				h.gameManager.Publish(event)
 			}
 			delete(h.matchmaking, username)
 		}
@@ -206,34 +343,18 @@ func (h *Hub) createGame(username1 string, client1 *Client, username2 string, cl
 	client1.gameID = gameID
 	client2.gameID = gameID
 
-	// Notify both players
-	startMsg := &Message{
-		Type: "game_start",
-		Payload: GameStartMessage{
-			GameID:   gameID,
-			Player1:  username1,
-			Player2:  username2,
-			IsBot:    false,
-			YourTurn: true, // Player1 goes first
-		},
-	}
-
-	client1.send <- startMsg
-
-	startMsg.Payload = GameStartMessage{
-		GameID:   gameID,
-		Player1:  username1,
-		Player2:  username2,
-		IsBot:    false,
-		YourTurn: false, // Player2 goes second
-	}
+	// Notify both players. The start event is always sequence 1; each player
+	// gets their own copy since YourTurn differs.
+	seq := h.recordGameEvent(gameState, gameStartMessage(gameState, username1))
+	h.gameManager.Publish(newGameEvent(eventschema.GameStarted, gameState, seq))
 
-	client2.send <- startMsg
+	client1.trySend(gameStartMessage(gameState, username1))
+	client2.trySend(gameStartMessage(gameState, username2))
 
-	log.Printf("Game created: %s between %s and %s\n", gameID, username1, username2)
+	log.Printf("Game created: %s between %s and %s (seq %d)\n", gameID, username1, username2, seq)
 }
 
-func (h *Hub) createGameWithBot(username string, client *Client) {
+func (h *Hub) createGameWithBot(username string, client *Client, difficulty string) *GameState {
 	gameID := uuid.New().String()
 	
 	gameState := &GameState{
@@ -245,6 +366,7 @@ func (h *Hub) createGameWithBot(username string, client *Client) {
 		Status:        "active",
 		Winner:        "",
 		IsBot:         true,
+		BotDifficulty: difficulty,
 		CreatedAt:     time.Now().Format(time.RFC3339),
 	}
 
@@ -252,62 +374,216 @@ func (h *Hub) createGameWithBot(username string, client *Client) {
 	client.gameID = gameID
 
 	// Notify player
-	startMsg := &Message{
+	seq := h.recordGameEvent(gameState, gameStartMessage(gameState, username))
+	client.trySend(gameStartMessage(gameState, username))
+
+	h.gameManager.Publish(newGameEvent(eventschema.GameStarted, gameState, seq))
+
+	log.Printf("Game created with bot: %s for %s\n", gameID, username)
+	return gameState
+}
+
+// gameStartMessage builds the game_start event as seen by username.
+func gameStartMessage(gameState *GameState, username string) *Message {
+	return &Message{
 		Type: "game_start",
+		Seq:  1,
 		Payload: GameStartMessage{
-			GameID:   gameID,
-			Player1:  username,
-			Player2:  "Bot",
-			IsBot:    true,
-			YourTurn: true,
+			GameID:   gameState.ID,
+			Player1:  gameState.Player1,
+			Player2:  gameState.Player2,
+			IsBot:    gameState.IsBot,
+			YourTurn: gameState.Player1 == username, // Player1 goes first
 		},
 	}
+}
 
-	client.send <- startMsg
+// recordGameEvent stamps msg with the game's next sequence number and appends
+// it to the game's event log so it can be replayed on resync. Callers must
+// hold h.mu.
+func (h *Hub) recordGameEvent(gameState *GameState, msg *Message) int64 {
+	gameState.Seq++
+	msg.Seq = gameState.Seq
+	gameState.events = append(gameState.events, msg)
+	return msg.Seq
+}
 
-	log.Printf("Game created with bot: %s for %s\n", gameID, username)
+// publishToGame records msg in the game's event log and broadcasts it to every
+// client in the game, returning the sequence number it was assigned. It is
+// queued while the lock is held so clients receive events in seq order.
+func (h *Hub) publishToGame(gameState *GameState, msg *Message) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seq := h.recordGameEvent(gameState, msg)
+	h.broadcastToGame(gameState.ID, msg)
+	return seq
+}
+
+// Resync replays every event of the game after fromSeq to the client, in
+// order, so a client that missed events can catch up without reloading.
+func (h *Hub) Resync(client *Client, requestID string, gameID string, fromSeq int64) {
+	if gameID == "" {
+		gameID = client.gameID
+	}
+
+	h.mu.RLock()
+	gameState := h.games[gameID]
+	var missed []*Message
+	var latest int64
+	if gameState != nil {
+		for _, msg := range gameState.events {
+			if msg.Seq > fromSeq {
+				missed = append(missed, msg)
+			}
+		}
+		latest = gameState.Seq
+	}
+	h.mu.RUnlock()
+
+	if gameState == nil {
+		client.sendError(requestID, "Game not found")
+		return
+	}
+	if gameState.Player1 != client.username && gameState.Player2 != client.username {
+		client.sendError(requestID, "Not a player in this game")
+		return
+	}
+
+	for _, msg := range missed {
+		if msg.Type == "game_start" {
+			msg = gameStartMessage(gameState, client.username)
+		}
+		if !client.trySend(msg) {
+			return
+		}
+	}
+
+	client.sendAck(requestID, "resync", latest)
+	log.Printf("Resynced %s on game %s from seq %d (%d events)\n", client.username, gameID, fromSeq, len(missed))
+}
+
+// Snapshot sends the client the current full state of a game.
+func (h *Hub) Snapshot(client *Client, requestID string, gameID string) {
+	if gameID == "" {
+		gameID = client.gameID
+	}
+
+	h.mu.RLock()
+	gameState := h.games[gameID]
+	var snapshot GameSnapshotMessage
+	if gameState != nil {
+		snapshot = GameSnapshotMessage{
+			GameID:        gameState.ID,
+			Player1:       gameState.Player1,
+			Player2:       gameState.Player2,
+			IsBot:         gameState.IsBot,
+			Board:         gameState.Board.Grid,
+			CurrentPlayer: gameState.CurrentPlayer,
+			Status:        gameState.Status,
+			Winner:        gameState.Winner,
+			Seq:           gameState.Seq,
+		}
+	}
+	h.mu.RUnlock()
+
+	if gameState == nil {
+		client.sendError(requestID, "Game not found")
+		return
+	}
+	if snapshot.Player1 != client.username && snapshot.Player2 != client.username {
+		client.sendError(requestID, "Not a player in this game")
+		return
+	}
+
+	client.trySend(&Message{
+		Type:      "game_snapshot",
+		RequestID: requestID,
+		Payload:   snapshot,
+	})
 }
 
-func (h *Hub) HandleGameMove(client *Client, column int) {
+// boardSnapshot copies the grid so later moves don't alter queued events.
+func boardSnapshot(board *Board) *[ROWS][COLS]int {
+	grid := board.Grid
+	return &grid
+}
+
+// Rejoin attaches a reconnecting player to their game again and cancels its
+// pending forfeit. Only the game's players may rejoin it.
+func (h *Hub) Rejoin(client *Client, requestID string, gameID string) {
+	h.mu.RLock()
+	gameState := h.games[gameID]
+	var seq int64
+	if gameState != nil {
+		seq = gameState.Seq
+	}
+	h.mu.RUnlock()
+
+	if gameState == nil {
+		client.sendError(requestID, "Game not found")
+		return
+	}
+	if gameState.Player1 != client.username && gameState.Player2 != client.username {
+		client.sendError(requestID, "Not a player in this game")
+		return
+	}
+
+	client.gameID = gameID
+	client.sendAck(requestID, "rejoin", seq)
+	h.cancelForfeit(client)
+	h.publishReconnect(client)
+	log.Printf("Player %s rejoining game %s\n", client.username, gameID)
+}
+
+func (h *Hub) HandleGameMove(client *Client, requestID string, column int) {
 	h.mu.Lock()
 	gameState := h.games[client.gameID]
 	h.mu.Unlock()
 
 	if gameState == nil {
-		client.send <- &Message{
-			Type:    "error",
-			Payload: map[string]string{"message": "Game not found"},
-		}
+		client.sendError(requestID, "Game not found")
+		return
+	}
+
+	if gameState.Status != "active" {
+		client.sendError(requestID, "Game is over")
 		return
 	}
 
 	// Determine which player made the move
 	var player int
-	if gameState.Player1 == client.username {
+	switch client.username {
+	case gameState.Player1:
 		player = PLAYER1
-	} else {
+	case gameState.Player2:
 		player = PLAYER2
+	default:
+		client.sendError(requestID, "Not a player in this game")
+		return
 	}
 
 	// Validate it's the player's turn
 	if gameState.CurrentPlayer != player {
-		client.send <- &Message{
-			Type:    "error",
-			Payload: map[string]string{"message": "Not your turn"},
-		}
+		client.sendError(requestID, "Not your turn")
 		return
 	}
 
 	// Make the move
 	row, err := gameState.Board.DropDisc(column, player)
 	if err != nil {
-		client.send <- &Message{
-			Type:    "error",
-			Payload: map[string]string{"message": err.Error()},
-		}
+		client.sendError(requestID, err.Error())
 		return
 	}
 
+	gameState.Moves = append(gameState.Moves, Move{
+		Number:   len(gameState.Moves) + 1,
+		Player:   player,
+		Column:   column,
+		Row:      row,
+		PlayedAt: time.Now(),
+	})
+
 	// Switch turn BEFORE broadcasting
 	if gameState.CurrentPlayer == PLAYER1 {
 		gameState.CurrentPlayer = PLAYER2
@@ -323,18 +599,20 @@ func (h *Hub) HandleGameMove(client *Client, column int) {
 			Column:        column,
 			Row:           row,
 			Player:        player,
-			Board:         gameState.Board.Grid,
+			Board:         boardSnapshot(gameState.Board),
 			CurrentPlayer: gameState.CurrentPlayer, // Updated player after switch
 		},
 	}
 
-	h.broadcastToGame(client.gameID, moveMsg)
+	seq := h.publishToGame(gameState, moveMsg)
+	client.sendAck(requestID, "game_move", seq)
+	h.gameManager.PublishMoveEvent(gameState, seq, gameState.Moves[len(gameState.Moves)-1])
 
 	// Check for win
 	if gameState.Board.CheckWin(row, column, player) {
-		gameState.Status = "finished"
-		gameState.Winner = client.username
-		gameState.UpdatedAt = time.Now().Format(time.RFC3339)
+		if !h.finishGame(gameState, client.username) {
+			return
+		}
 
 		resultMsg := &Message{
 			Type: "game_result",
@@ -346,16 +624,16 @@ func (h *Hub) HandleGameMove(client *Client, column int) {
 			},
 		}
 
-		h.broadcastToGame(client.gameID, resultMsg)
-		h.gameManager.SaveGame(gameState)
+		h.publishToGame(gameState, resultMsg)
+		h.saveGame(gameState)
 		return
 	}
 
 	// Check for draw
 	if gameState.Board.IsBoardFull() {
-		gameState.Status = "finished"
-		gameState.Winner = "draw"
-		gameState.UpdatedAt = time.Now().Format(time.RFC3339)
+		if !h.finishGame(gameState, "draw") {
+			return
+		}
 
 		resultMsg := &Message{
 			Type: "game_result",
@@ -365,8 +643,8 @@ func (h *Hub) HandleGameMove(client *Client, column int) {
 			},
 		}
 
-		h.broadcastToGame(client.gameID, resultMsg)
-		h.gameManager.SaveGame(gameState)
+		h.publishToGame(gameState, resultMsg)
+		h.saveGame(gameState)
 		return
 	}
 
@@ -378,7 +656,12 @@ func (h *Hub) HandleGameMove(client *Client, column int) {
 }
 
 func (h *Hub) makeBotMove(gameState *GameState, playerClient *Client) {
-	bot := NewBot("medium")
+	// The player may have forfeited while the bot was thinking
+	if gameState.Status != "active" {
+		return
+	}
+
+	bot := NewBot(gameState.BotDifficulty)
 	column := bot.GetBotMove(gameState.Board, PLAYER2, PLAYER1)
 
 	if column == -1 {
@@ -391,6 +674,14 @@ func (h *Hub) makeBotMove(gameState *GameState, playerClient *Client) {
 		return
 	}
 
+	gameState.Moves = append(gameState.Moves, Move{
+		Number:   len(gameState.Moves) + 1,
+		Player:   PLAYER2,
+		Column:   column,
+		Row:      row,
+		PlayedAt: time.Now(),
+	})
+
 	// Switch turn back to player 1
 	gameState.CurrentPlayer = PLAYER1
 
@@ -402,18 +693,19 @@ func (h *Hub) makeBotMove(gameState *GameState, playerClient *Client) {
 			Column:        column,
 			Row:           row,
 			Player:        PLAYER2,
-			Board:         gameState.Board.Grid,
+			Board:         boardSnapshot(gameState.Board),
 			CurrentPlayer: PLAYER1, // Switched to player 1 after bot's move
 		},
 	}
 
-	h.broadcastToGame(gameState.ID, moveMsg)
+	seq := h.publishToGame(gameState, moveMsg)
+	h.gameManager.PublishMoveEvent(gameState, seq, gameState.Moves[len(gameState.Moves)-1])
 
 	// Check for win
 	if gameState.Board.CheckWin(row, column, PLAYER2) {
-		gameState.Status = "finished"
-		gameState.Winner = "Bot"
-		gameState.UpdatedAt = time.Now().Format(time.RFC3339)
+		if !h.finishGame(gameState, "Bot") {
+			return
+		}
 
 		resultMsg := &Message{
 			Type: "game_result",
@@ -425,16 +717,16 @@ func (h *Hub) makeBotMove(gameState *GameState, playerClient *Client) {
 			},
 		}
 
-		h.broadcastToGame(gameState.ID, resultMsg)
-		h.gameManager.SaveGame(gameState)
+		h.publishToGame(gameState, resultMsg)
+		h.saveGame(gameState)
 		return
 	}
 
 	// Check for draw
 	if gameState.Board.IsBoardFull() {
-		gameState.Status = "finished"
-		gameState.Winner = "draw"
-		gameState.UpdatedAt = time.Now().Format(time.RFC3339)
+		if !h.finishGame(gameState, "draw") {
+			return
+		}
 
 		resultMsg := &Message{
 			Type: "game_result",
@@ -444,8 +736,8 @@ func (h *Hub) makeBotMove(gameState *GameState, playerClient *Client) {
 			},
 		}
 
-		h.broadcastToGame(gameState.ID, resultMsg)
-		h.gameManager.SaveGame(gameState)
+		h.publishToGame(gameState, resultMsg)
+		h.saveGame(gameState)
 		return
 	}
 
@@ -464,48 +756,265 @@ func (h *Hub) handlePlayerDisconnect(client *Client) {
 	// Mark disconnection time
 	client.closedAt = time.Now()
 
-	// Wait 30 seconds for reconnection
-	go func() {
-		time.Sleep(30 * time.Second)
-
+	h.mu.RLock()
+	seq := gameState.Seq
+	h.mu.RUnlock()
+	event := newGameEvent(eventschema.PlayerDisconnected, gameState, seq)
+	event.EventID = uuid.New().String()
+	event.Player, event.Opponent = client.username, opponentOf(gameState, client.username)
+	h.gameManager.Publish(event)
+
+	// Wait for reconnection; rejoining cancels the forfeit
+	username := client.username
+	h.mu.Lock()
+	if gameState.forfeits == nil {
+		gameState.forfeits = make(map[string]*time.Timer)
+	}
+	if timer := gameState.forfeits[username]; timer != nil {
+		timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(reconnectWindow, func() {
 		h.mu.Lock()
-		defer h.mu.Unlock()
-
-		gameState := h.games[client.gameID]
-		if gameState == nil || gameState.Status == "finished" {
+		pending := gameState.forfeits[username] == timer
+		if pending {
+			delete(gameState.forfeits, username)
+		}
+		h.mu.Unlock()
+		if !pending {
 			return
 		}
 
 		// Still disconnected - forfeit
-		gameState.Status = "finished"
-		gameState.UpdatedAt = time.Now().Format(time.RFC3339)
+		winner := opponentOf(gameState, username)
 
-		if gameState.IsBot {
-			gameState.Winner = "Bot"
-		} else {
-			if gameState.Player1 == client.username {
-				gameState.Winner = gameState.Player2
-			} else {
-				gameState.Winner = gameState.Player1
-			}
+		// The game may have ended normally in the meantime
+		if !h.finishGame(gameState, winner) {
+			return
 		}
+		gameState.Forfeit = true
 
-		h.gameManager.SaveGame(gameState)
-		log.Printf("Game %s forfeited due to player disconnect\n", client.gameID)
-	}()
+		h.saveGame(gameState)
+		log.Printf("Game %s forfeited due to player disconnect\n", gameState.ID)
+	})
+	gameState.forfeits[username] = timer
+	h.mu.Unlock()
 }
 
-func (h *Hub) broadcastToGame(gameID string, msg interface{}) {
+// cancelForfeit stops the pending disconnect forfeit of a player rejoining
+// their game.
+func (h *Hub) cancelForfeit(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	gameState := h.games[client.gameID]
+	if gameState == nil {
+		return
+	}
+	if timer := gameState.forfeits[client.username]; timer != nil {
+		timer.Stop()
+		delete(gameState.forfeits, client.username)
+		log.Printf("Cancelled forfeit of %s in game %s\n", client.username, client.gameID)
+	}
+}
+
+// publishReconnect publishes player_reconnected when a player rejoins a game
+// that is still being played.
+func (h *Hub) publishReconnect(client *Client) {
+	h.mu.RLock()
+	gameState := h.games[client.gameID]
+	var seq int64
+	if gameState != nil {
+		seq = gameState.Seq
+	}
+	h.mu.RUnlock()
+
+	if gameState == nil || gameState.Status != "active" {
+		return
+	}
+	if gameState.Player1 != client.username && gameState.Player2 != client.username {
+		return
+	}
+
+	event := newGameEvent(eventschema.PlayerReconnected, gameState, seq)
+	event.EventID = uuid.New().String()
+	event.Player, event.Opponent = client.username, opponentOf(gameState, client.username)
+	h.gameManager.Publish(event)
+}
+
+// opponentOf returns the other player in gameState.
+func opponentOf(gameState *GameState, username string) string {
+	if gameState.Player1 == username {
+		return gameState.Player2
+	}
+	return gameState.Player1
+}
+
+// saveGame records a finished game and tells its players about any
+// achievements it unlocked.
+func (h *Hub) saveGame(gameState *GameState) {
+	unlocked, err := h.gameManager.SaveGame(gameState)
+	if err != nil {
+		return
+	}
+
+	for username, achievements := range unlocked {
+		h.sendToUser(username, &Message{
+			Type:    "achievement_unlocked",
+			Payload: AchievementUnlockedMessage{Achievements: achievements},
+		})
+	}
+}
+
+// sendToUser queues msg for every connected client of username.
+func (h *Hub) sendToUser(username string, msg *Message) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
+	for client := range h.clients {
+		if client.username == username {
+			client.trySend(msg)
+		}
+	}
+}
+
+// finishGame moves an active game to finished with the given winner. It
+// reports false if the game had already finished, so exactly one of the
+// finishing move and the disconnect forfeit records the result.
+func (h *Hub) finishGame(gameState *GameState, winner string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if gameState.Status == "finished" {
+		return false
+	}
+
+	gameState.Status = "finished"
+	gameState.Winner = winner
+	gameState.UpdatedAt = time.Now().Format(time.RFC3339)
+	return true
+}
+
+// broadcastToGame queues msg for every client in the game. Callers must hold
+// h.mu.
+func (h *Hub) broadcastToGame(gameID string, msg interface{}) {
 	for client := range h.clients {
 		if client.gameID == gameID {
-			select {
-			case client.send <- msg:
-			default:
-				// Client's send channel is full
+			client.trySend(msg)
+		}
+	}
+}
+
+func (c *Client) sendAck(requestID string, msgType string, seq int64) {
+	if requestID == "" {
+		return
+	}
+	c.trySend(&Message{
+		Type:      "ack",
+		RequestID: requestID,
+		Payload:   AckMessage{Type: msgType, Seq: seq},
+	})
+}
+
+func (c *Client) sendError(requestID string, message string) {
+	c.trySend(&Message{
+		Type:      "error",
+		RequestID: requestID,
+		Payload:   ErrorMessage{Message: message},
+	})
+}
+
+// trySend queues msg for the client without blocking. A client whose buffer is
+// full has fallen behind and would see a desynced board, so it is evicted
+// rather than having the message silently dropped.
+func (c *Client) trySend(msg interface{}) bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if c.sendClosed {
+		return false
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		c.evict("send buffer full")
+		return false
+	}
+}
+
+// closeSend closes the send channel once the client is unregistered. Sends
+// from HTTP actions can still race with it, so they check sendClosed.
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.sendClosed = true
+	close(c.send)
+}
+
+// evict closes the client's connection. Its read loop then fails and the
+// client goes through the regular unregister and disconnect flow.
+func (c *Client) evict(reason string) {
+	c.evictOnce.Do(func() {
+		log.Printf("Evicting client %s: %s\n", c.username, reason)
+		c.conn.Close()
+	})
+}
+
+// writePump sends queued messages and periodic pings over a streaming
+// transport. A failed write closes the connection so the transport notices and
+// unregisters the client.
+func (c *Client) writePump(conn streamConn) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			if !ok {
+				// The hub closed the channel
+				conn.WriteClose()
+				return
+			}
+			if err := conn.WriteMessage(c.prepare(msg)); err != nil {
+				log.Println("Write error:", err)
+				return
+			}
+
+		case <-ticker.C:
+			if err := conn.WritePing(); err != nil {
+				log.Println("Ping error:", err)
+				return
 			}
 		}
 	}
 }
+
+// prepare adapts a queued message to what this client has already seen. Clients
+// that asked for move deltas get game_move events without the board once they
+// hold a snapshot of that game (from game_start or game_snapshot).
+func (c *Client) prepare(msg interface{}) interface{} {
+	m, ok := msg.(*Message)
+	if !ok {
+		return msg
+	}
+
+	switch payload := m.Payload.(type) {
+	case GameStartMessage:
+		c.snapshotGame = payload.GameID
+	case GameSnapshotMessage:
+		c.snapshotGame = payload.GameID
+	case GameMoveEventMessage:
+		if c.deltaMoves && c.snapshotGame == payload.GameID {
+			payload.Board = nil
+			delta := *m
+			delta.Payload = payload
+			return &delta
+		}
+	}
+	return msg
+}
@@ -2,10 +2,11 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"time"
 
+	"eventschema"
+
 	"github.com/segmentio/kafka-go"
 )
 
@@ -13,48 +14,67 @@ type KafkaProducer struct {
 	writer *kafka.Writer
 }
 
-type GameEvent struct {
-	EventType   string    `json:"eventType"`
-	GameID      string    `json:"gameId"`
-	Player      string    `json:"player"`
-	Opponent    string    `json:"opponent"`
-	Action      string    `json:"action"`
-	Column      int       `json:"column,omitempty"`
-	Row         int       `json:"row,omitempty"`
-	Timestamp   time.Time `json:"timestamp"`
-	IsBot       bool      `json:"isBot"`
-	GameResult  string    `json:"gameResult,omitempty"`
-	Duration    int       `json:"duration,omitempty"`
-}
+// GameEvent is an analytics event; its schema is shared with the analytics
+// service.
+type GameEvent = eventschema.Event
 
+// NewKafkaProducer connects to broker first so an unreachable Kafka is
+// reported at startup rather than on every publish.
 func NewKafkaProducer(broker string, topic string) (*KafkaProducer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := kafka.DialContext(ctx, "tcp", broker)
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+
 	writer := kafka.NewWriter(kafka.WriterConfig{
 		Brokers:      []string{broker},
 		Topic:        topic,
 		RequiredAcks: int(kafka.RequireOne),
+		// Route by key; the default round-robin ignores it and would spread a
+		// game's events across partitions
+		Balancer: &kafka.Hash{},
+		// Batching happens in AsyncPublisher; don't wait for more messages
+		BatchTimeout: 10 * time.Millisecond,
 	})
 
 	return &KafkaProducer{writer: writer}, nil
 }
 
-func (kp *KafkaProducer) PublishEvent(event GameEvent) error {
-	data, err := json.Marshal(event)
-	if err != nil {
-		log.Printf("Error marshaling event: %v\n", err)
-		return err
-	}
+func (kp *KafkaProducer) Publish(event GameEvent) error {
+	return kp.PublishBatch([]GameEvent{event})
+}
 
-	msg := kafka.Message{
-		Key:   []byte(event.GameID),
-		Value: data,
+// PublishBatch writes events in one request, keyed by game so each game's
+// events stay ordered within a partition. Events outside a game are keyed by
+// player.
+func (kp *KafkaProducer) PublishBatch(events []GameEvent) error {
+	msgs := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		data, err := eventschema.Encode(event)
+		if err != nil {
+			log.Printf("Error marshaling event: %v\n", err)
+			return err
+		}
+		key := event.GameID
+		if key == "" {
+			key = event.Player
+		}
+		msgs = append(msgs, kafka.Message{
+			Key:   []byte(key),
+			Value: data,
+		})
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	err = kp.writer.WriteMessages(ctx, msg)
+	err := kp.writer.WriteMessages(ctx, msgs...)
 	if err != nil {
-		log.Printf("Error publishing event to Kafka: %v\n", err)
+		log.Printf("Error publishing events to Kafka: %v\n", err)
 		return err
 	}
 
@@ -67,61 +87,57 @@ func (kp *KafkaProducer) Close() error {
 
 // GameManager manages games and publishes events
 type GameManager struct {
-	db       *Database
-	producer *KafkaProducer
+	db     Store
+	events EventSink
 }
 
-func NewGameManager(db *Database, producer *KafkaProducer) *GameManager {
+func NewGameManager(db Store, events EventSink) *GameManager {
+	if events == nil {
+		events = NoopSink{}
+	}
 	return &GameManager{
-		db:       db,
-		producer: producer,
+		db:     db,
+		events: events,
 	}
 }
 
-func (gm *GameManager) SaveGame(game *GameState) error {
+// SaveGame records a finished game, evaluates achievements and writes
+// game_completed to the outbox in the same transaction as the result; the
+// outbox relay publishes it. It returns the achievements each player
+// unlocked. Saving the same game twice is harmless: stats, achievements and
+// the event are only produced once.
+func (gm *GameManager) SaveGame(game *GameState) (map[string][]Achievement, error) {
+	_, _, duration := gameTimes(game)
+
+	event := newGameEvent(eventschema.GameCompleted, game, game.Seq)
+	// Keyed without seq, as before the envelope, so outbox IDs don't change
+	event.EventID = eventschema.StableID(game.ID, eventschema.GameCompleted)
+	event.GameResult = game.Winner
+	event.Duration = duration
+	if game.Forfeit {
+		event.Reason = eventschema.ReasonDisconnectForfeit
+	}
+
 	// Save to database
-	err := gm.db.SaveGame(game)
+	applied, err := gm.db.RecordResult(game, event)
 	if err != nil {
 		log.Printf("Error saving game to database: %v\n", err)
-		return err
-	}
-
-	createdAt, _ := time.Parse(time.RFC3339, game.CreatedAt)
-	updatedAt, _ := time.Parse(time.RFC3339, game.UpdatedAt)
-	duration := int(updatedAt.Sub(createdAt).Seconds())
-
-	// Publish event
-	event := GameEvent{
-		EventType:  "game_completed",
-		GameID:     game.ID,
-		Player:     game.Player1,
-		Opponent:   game.Player2,
-		Timestamp:  time.Now(),
-		IsBot:      game.IsBot,
-		GameResult: game.Winner,
-		Duration:   duration,
+		return nil, err
 	}
-
-	if err := gm.producer.PublishEvent(event); err != nil {
-		log.Printf("Error publishing game event: %v\n", err)
-		// Don't fail - game is already saved
+	if !applied {
+		return nil, nil
 	}
 
-	return nil
+	return EvaluateAchievements(gm.db, game), nil
 }
 
-func (gm *GameManager) PublishMoveEvent(gameID string, player string, opponent string, column int, row int, isBot bool) error {
-	event := GameEvent{
-		EventType: "game_move",
-		GameID:    gameID,
-		Player:    player,
-		Opponent:  opponent,
-		Action:    "move",
-		Column:    column,
-		Row:       row,
-		Timestamp: time.Now(),
-		IsBot:     isBot,
+// BotDifficulty returns the bot difficulty username prefers, falling back to
+// the default if their profile can't be read.
+func (gm *GameManager) BotDifficulty(username string) string {
+	profile, err := gm.db.GetProfile(username)
+	if err != nil {
+		log.Printf("Error loading profile for %s: %v\n", username, err)
+		return defaultProfile(username).Preferences.BotDifficulty
 	}
-
-	return gm.producer.PublishEvent(event)
+	return profile.Preferences.BotDifficulty
 }
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLeaderboardLimit = 100
+	maxLeaderboardLimit     = 100
+)
+
+// LeaderboardQuery selects a page of a leaderboard. Standings are computed
+// from the finished games started since Since (all of them if zero); ratings
+// are always the players' current ones.
+type LeaderboardQuery struct {
+	Window      string // "daily", "weekly", "monthly" or "all"
+	Since       time.Time
+	SortBy      string // "rating", "wins" or "winRate"
+	MinGames    int
+	ExcludeBots bool
+	Offset      int
+	Limit       int
+	Player      string // also return this player's entry, wherever they rank
+}
+
+type LeaderboardEntry struct {
+	Rank     int     `json:"rank"`
+	Username string  `json:"username"`
+	Rating   int     `json:"rating"`
+	Games    int     `json:"games"`
+	Wins     int     `json:"wins"`
+	Losses   int     `json:"losses"`
+	Draws    int     `json:"draws"`
+	WinRate  float64 `json:"winRate"` // percentage, 0-100
+}
+
+type Leaderboard struct {
+	Window  string             `json:"window"`
+	SortBy  string             `json:"sort"`
+	Total   int                `json:"total"` // ranked players in the window
+	Offset  int                `json:"offset"`
+	Entries []LeaderboardEntry `json:"leaderboard"`
+	Player  *LeaderboardEntry  `json:"player,omitempty"`
+}
+
+// leaderboardOrder is the SQL ordering for each sort key. Ties fall back to
+// the other counters, then the username.
+var leaderboardOrder = map[string]string{
+	"rating":  "rating DESC, wins DESC",
+	"wins":    "wins DESC, win_rate DESC",
+	"winRate": "win_rate DESC, games DESC",
+}
+
+// windowStart returns when the current calendar window began in UTC; weeks
+// start on Monday. The all-time window has no start.
+func windowStart(window string, now time.Time) (time.Time, error) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch window {
+	case "all":
+		return time.Time{}, nil
+	case "daily":
+		return today, nil
+	case "weekly":
+		return today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7)), nil
+	case "monthly":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	default:
+		return time.Time{}, fmt.Errorf("window must be daily, weekly, monthly or all")
+	}
+}
+
+// parseLeaderboardQuery reads the leaderboard options from the query string.
+func parseLeaderboardQuery(c *gin.Context) (LeaderboardQuery, error) {
+	q := LeaderboardQuery{
+		Window: c.DefaultQuery("window", "all"),
+		SortBy: c.DefaultQuery("sort", "wins"),
+		Player: c.Query("player"),
+		Limit:  defaultLeaderboardLimit,
+	}
+
+	var err error
+	if q.Since, err = windowStart(q.Window, time.Now()); err != nil {
+		return q, err
+	}
+	if _, ok := leaderboardOrder[q.SortBy]; !ok {
+		return q, fmt.Errorf("sort must be rating, wins or winRate")
+	}
+
+	if v := c.Query("excludeBots"); v != "" {
+		if q.ExcludeBots, err = strconv.ParseBool(v); err != nil {
+			return q, fmt.Errorf("excludeBots must be true or false")
+		}
+	}
+
+	ints := []struct {
+		name string
+		dst  *int
+		min  int
+	}{
+		{"minGames", &q.MinGames, 0},
+		{"offset", &q.Offset, 0},
+		{"limit", &q.Limit, 1},
+	}
+	for _, p := range ints {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < p.min {
+			return q, fmt.Errorf("%s must be a number of at least %d", p.name, p.min)
+		}
+		*p.dst = n
+	}
+	if q.Limit > maxLeaderboardLimit {
+		q.Limit = maxLeaderboardLimit
+	}
+	return q, nil
+}
+
+// percent turns a win fraction into a percentage with two decimals.
+func percent(fraction float64) float64 {
+	return math.Round(fraction*10000) / 100
+}
+
+// rankLeaderboard sorts every qualifying entry, numbers them and cuts out the
+// requested page. It is the in-memory equivalent of Database.GetLeaderboard.
+func rankLeaderboard(entries []LeaderboardEntry, q LeaderboardQuery) *Leaderboard {
+	// Same keys as leaderboardOrder
+	keys := func(e LeaderboardEntry) [2]float64 {
+		switch q.SortBy {
+		case "rating":
+			return [2]float64{float64(e.Rating), float64(e.Wins)}
+		case "winRate":
+			return [2]float64{e.WinRate, float64(e.Games)}
+		default:
+			return [2]float64{float64(e.Wins), e.WinRate}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		ki, kj := keys(entries[i]), keys(entries[j])
+		for k := range ki {
+			if ki[k] != kj[k] {
+				return ki[k] > kj[k]
+			}
+		}
+		return entries[i].Username < entries[j].Username
+	})
+
+	board := &Leaderboard{
+		Window:  q.Window,
+		SortBy:  q.SortBy,
+		Total:   len(entries),
+		Offset:  q.Offset,
+		Entries: make([]LeaderboardEntry, 0),
+	}
+	for i := range entries {
+		entries[i].Rank = i + 1
+		if i >= q.Offset && i < q.Offset+q.Limit {
+			board.Entries = append(board.Entries, entries[i])
+		}
+		if entries[i].Username == q.Player {
+			entry := entries[i]
+			board.Player = &entry
+		}
+	}
+	return board
+}
+
+func (s *Server) getLeaderboard(c *gin.Context) {
+	q, err := parseLeaderboardQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	leaderboard, err := s.db.GetLeaderboard(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, leaderboard)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"eventschema"
+
+	"github.com/google/uuid"
+)
+
+// newGameEvent fills in the envelope of an event about gameState at seq. Its
+// ID is derived from the game, type and sequence number, so the same event is
+// never counted twice downstream.
+func newGameEvent(eventType string, gameState *GameState, seq int64) GameEvent {
+	return GameEvent{
+		EventID:       eventschema.StableID(gameState.ID, eventType, strconv.FormatInt(seq, 10)),
+		SchemaVersion: eventschema.CurrentVersion,
+		EventType:     eventType,
+		GameID:        gameState.ID,
+		Seq:           seq,
+		Player:        gameState.Player1,
+		Opponent:      gameState.Player2,
+		IsBot:         gameState.IsBot,
+		Timestamp:     time.Now(),
+	}
+}
+
+// newPlayerEvent fills in the envelope of an event about a player outside a
+// game, such as joining the matchmaking queue.
+func newPlayerEvent(eventType string, username string) GameEvent {
+	return GameEvent{
+		EventID:       uuid.New().String(),
+		SchemaVersion: eventschema.CurrentVersion,
+		EventType:     eventType,
+		Player:        username,
+		Timestamp:     time.Now(),
+	}
+}
+
+// stampPublished records when events were handed to the sink.
+func stampPublished(events []GameEvent) {
+	now := time.Now()
+	for i := range events {
+		events[i].PublishedAt = &now
+	}
+}
+
+// Publish sends a lifecycle event. Failures are logged, never returned:
+// analytics must not get in the way of a game.
+func (gm *GameManager) Publish(event GameEvent) {
+	if err := gm.events.Publish(event); err != nil {
+		log.Printf("Error publishing %s event: %v\n", event.EventType, err)
+	}
+}
+
+// PublishMoveEvent publishes the move numbered move.Number in gameState, sent
+// to clients with sequence number seq.
+func (gm *GameManager) PublishMoveEvent(gameState *GameState, seq int64, move Move) {
+	event := newGameEvent(eventschema.GameMove, gameState, seq)
+	event.Action = "move"
+	event.MoveNumber = move.Number
+	event.Column = move.Column
+	event.Row = move.Row
+	if move.Player == PLAYER2 {
+		event.Player, event.Opponent = gameState.Player2, gameState.Player1
+	}
+	event.Timestamp = move.PlayedAt
+	gm.Publish(event)
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,52 +20,137 @@ func main() {
 		port = "8080"
 	}
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL not set")
-	}
-
-	kafkaBroker := os.Getenv("KAFKA_BROKER")
-	if kafkaBroker == "" {
-		kafkaBroker = "localhost:9092"
-	}
+	storeConfig := LoadStoreConfig()
 
-	kafkaTopic := os.Getenv("KAFKA_TOPIC")
-	if kafkaTopic == "" {
-		kafkaTopic = "game_events"
+	// `backend migrate up|down|status` manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := RunMigrateCommand(storeConfig, os.Args[2:]); err != nil {
+			log.Fatal("Migration failed:", err)
+		}
+		return
 	}
 
-	// Initialize database
-	db, err := InitDB(dbURL)
+	// Initialize storage (Postgres by default; STORE=sqlite or memory needs no services)
+	db, err := OpenStore(storeConfig)
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
 	defer db.Close()
 
-	// Initialize Kafka producer (optional, skip if not available)
-	var producer *KafkaProducer
-	if kafkaBroker != "" {
-		var err error
-		producer, err = NewKafkaProducer(kafkaBroker, kafkaTopic)
-		if err != nil {
-			log.Println("Warning: Kafka not available, analytics disabled:", err)
+	// `backend archive` runs the retention job once; `backend delete-player
+	// <username>` deletes an account
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "archive":
+			retention := LoadRetentionConfig()
+			if err := retention.Validate(); err != nil {
+				log.Fatal("Invalid retention config:", err)
+			}
+			if retention.MaxAge == 0 {
+				log.Fatal("RETENTION_DAYS not set")
+			}
+			n, err := ArchiveOldGames(db, retention)
+			if err != nil {
+				log.Fatal("Archiving failed:", err)
+			}
+			log.Printf("Archived %d games\n", n)
+			return
+		case "delete-player":
+			if err := RunDeletePlayerCommand(db, os.Args[2:]); err != nil {
+				log.Fatal("Deleting player failed:", err)
+			}
+			return
 		}
 	}
-	if producer != nil {
-		defer producer.Close()
+
+	publisherConfig := LoadPublisherConfig()
+	if err := publisherConfig.Validate(); err != nil {
+		log.Fatal("Invalid event publisher config:", err)
+	}
+
+	// Archive old games in the background if a retention period is set
+	retention := LoadRetentionConfig()
+	if err := retention.Validate(); err != nil {
+		log.Fatal("Invalid retention config:", err)
+	}
+	if retention.MaxAge > 0 {
+		log.Printf("Archiving games older than %s to %s\n", retention.MaxAge, retention.ArchiveDir)
+		go RunRetention(db, retention)
+	}
+
+	// Analytics events go to Kafka, a file, memory or nowhere (EVENT_SINK)
+	sinkConfig := LoadEventSinkConfig()
+	events, sinkErr := OpenEventSink(sinkConfig)
+	if sinkErr != nil {
+		log.Println("Warning: event sink not available, live events disabled:", sinkErr)
+		events = NoopSink{}
+	}
+
+	// Publish from a background queue so a slow sink never stalls gameplay
+	publisher := NewAsyncPublisher(events, publisherConfig)
+	defer publisher.Close()
+
+	// Game results reach the sink through the outbox, written in the same
+	// transaction as the result. Stopped before the publisher closes the sink.
+	// Without a sink the relay keeps trying to open one and leaves results
+	// pending instead of dropping them.
+	relay := NewOutboxRelay(db, events, LoadOutboxConfig())
+	if sinkErr != nil {
+		relay = NewDeferredOutboxRelay(db, func() (EventSink, error) {
+			return OpenEventSink(sinkConfig)
+		}, LoadOutboxConfig())
 	}
+	go relay.Run()
+	defer relay.Stop()
 
 	// Initialize game manager
-	gameManager := NewGameManager(db, producer)
+	gameManager := NewGameManager(db, publisher)
 
 	// Initialize WebSocket hub
 	hub := NewHub(gameManager)
 	go hub.Run()
 
+	// Rate limits and abuse protection
+	guard := NewAbuseGuard(LoadAbuseConfig())
+
+	// Browser origins allowed to use the API and WebSocket
+	origins := LoadOriginPolicy()
+	log.Printf("Allowed origins (%s profile): %v\n", origins.profile, origins.List())
+
+	// Counters are served on a separate, internal listener
+	debugAddr := os.Getenv("DEBUG_ADDR")
+	if debugAddr == "" {
+		debugAddr = "127.0.0.1:6060"
+	}
+	if debugAddr != "none" {
+		go StartDebugServer(debugAddr)
+	}
+
 	// Start server
-	server := NewServer(port, hub, gameManager, db)
+	server, err := NewServer(port, hub, gameManager, db, guard, origins)
+	if err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 	log.Printf("Server starting on port %s\n", port)
-	if err := server.Start(); err != nil {
-		log.Fatal(err)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- server.Start() }()
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			log.Println("Server error:", err)
+		}
+	case <-ctx.Done():
+		log.Println("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Println("Server shutdown:", err)
+		}
 	}
+	// Deferred closes flush queued events and close the store
 }
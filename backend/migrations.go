@@ -0,0 +1,554 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// Arbitrary key for the Postgres advisory lock that serializes migration runs
+// across backend instances.
+const migrationLockKey = 4417
+
+// Migration is one numbered schema change. Versions must be unique and
+// increasing; a migration is never edited once released, only followed by a
+// new one.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// The first three migrations use IF NOT EXISTS so databases created before
+// schema_migrations existed are adopted as-is.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_players",
+		Up: `CREATE TABLE IF NOT EXISTS players (
+			id SERIAL PRIMARY KEY,
+			username VARCHAR(255) UNIQUE NOT NULL,
+			wins INT DEFAULT 0,
+			losses INT DEFAULT 0,
+			draws INT DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		Down: `DROP TABLE IF EXISTS players`,
+	},
+	{
+		Version: 2,
+		Name:    "create_games",
+		Up: `CREATE TABLE IF NOT EXISTS games (
+			id VARCHAR(36) PRIMARY KEY,
+			player1 VARCHAR(255),
+			player2 VARCHAR(255),
+			winner VARCHAR(255),
+			is_bot BOOLEAN DEFAULT false,
+			status VARCHAR(50),
+			board_state JSONB,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP,
+			duration_seconds INT
+		)`,
+		Down: `DROP TABLE IF EXISTS games`,
+	},
+	{
+		Version: 3,
+		Name:    "index_games",
+		Up: `CREATE INDEX IF NOT EXISTS idx_games_player1 ON games(player1);
+			CREATE INDEX IF NOT EXISTS idx_games_player2 ON games(player2);
+			CREATE INDEX IF NOT EXISTS idx_games_winner ON games(winner);
+			CREATE INDEX IF NOT EXISTS idx_games_created_at ON games(created_at)`,
+		Down: `DROP INDEX IF EXISTS idx_games_player1;
+			DROP INDEX IF EXISTS idx_games_player2;
+			DROP INDEX IF EXISTS idx_games_winner;
+			DROP INDEX IF EXISTS idx_games_created_at`,
+	},
+	{
+		Version: 4,
+		Name:    "create_moves",
+		Up: `CREATE TABLE moves (
+			game_id VARCHAR(36) NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+			move_number INT NOT NULL,
+			player INT NOT NULL,
+			col INT NOT NULL,
+			row_num INT NOT NULL,
+			played_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (game_id, move_number)
+		)`,
+		Down: `DROP TABLE moves`,
+	},
+	{
+		Version: 5,
+		Name:    "add_player_rating",
+		Up:      `ALTER TABLE players ADD COLUMN rating INT NOT NULL DEFAULT 1200`,
+		Down:    `ALTER TABLE players DROP COLUMN rating`,
+	},
+	{
+		Version: 6,
+		Name:    "create_achievements",
+		Up: `CREATE TABLE achievements (
+			username VARCHAR(255) NOT NULL,
+			achievement_id VARCHAR(64) NOT NULL,
+			unlocked_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (username, achievement_id)
+		)`,
+		Down: `DROP TABLE achievements`,
+	},
+	{
+		Version: 7,
+		Name:    "add_player_profile",
+		Up: `ALTER TABLE players ADD COLUMN display_name VARCHAR(32);
+			ALTER TABLE players ADD COLUMN avatar VARCHAR(32);
+			ALTER TABLE players ADD COLUMN country CHAR(2);
+			ALTER TABLE players ADD COLUMN bot_difficulty VARCHAR(16);
+			ALTER TABLE players ADD COLUMN board_variant VARCHAR(16)`,
+		Down: `ALTER TABLE players DROP COLUMN board_variant;
+			ALTER TABLE players DROP COLUMN bot_difficulty;
+			ALTER TABLE players DROP COLUMN country;
+			ALTER TABLE players DROP COLUMN avatar;
+			ALTER TABLE players DROP COLUMN display_name`,
+	},
+	{
+		Version: 8,
+		Name:    "create_outbox",
+		Up: `CREATE TABLE outbox (
+				id VARCHAR(36) PRIMARY KEY,
+				game_id VARCHAR(36) NOT NULL,
+				event_type VARCHAR(64) NOT NULL,
+				payload TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				delivered_at TIMESTAMP
+			);
+			CREATE INDEX idx_outbox_pending ON outbox(created_at) WHERE delivered_at IS NULL`,
+		Down: `DROP TABLE outbox`,
+	},
+	{
+		Version: 9,
+		Name:    "create_player_tokens",
+		Up: `CREATE TABLE player_tokens (
+			username VARCHAR(255) PRIMARY KEY,
+			token_hash CHAR(64) NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		Down: `DROP TABLE player_tokens`,
+	},
+	{
+		Version: 10,
+		Name:    "create_archived_totals",
+		Up: `CREATE TABLE archived_results (
+				username VARCHAR(255) PRIMARY KEY,
+				games INTEGER NOT NULL DEFAULT 0,
+				wins INTEGER NOT NULL DEFAULT 0,
+				draws INTEGER NOT NULL DEFAULT 0,
+				bot_games INTEGER NOT NULL DEFAULT 0,
+				bot_wins INTEGER NOT NULL DEFAULT 0,
+				bot_draws INTEGER NOT NULL DEFAULT 0
+			);
+			CREATE TABLE archived_head_to_head (
+				player_a VARCHAR(255) NOT NULL,
+				player_b VARCHAR(255) NOT NULL,
+				games INTEGER NOT NULL DEFAULT 0,
+				wins_a INTEGER NOT NULL DEFAULT 0,
+				wins_b INTEGER NOT NULL DEFAULT 0,
+				draws INTEGER NOT NULL DEFAULT 0,
+				streak_a INTEGER NOT NULL DEFAULT 0,
+				streak_b INTEGER NOT NULL DEFAULT 0,
+				longest_a INTEGER NOT NULL DEFAULT 0,
+				longest_b INTEGER NOT NULL DEFAULT 0,
+				duration_seconds BIGINT NOT NULL DEFAULT 0,
+				moves BIGINT NOT NULL DEFAULT 0,
+				first_mover_wins_a INTEGER NOT NULL DEFAULT 0,
+				first_mover_wins_b INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (player_a, player_b)
+			)`,
+		Down: `DROP TABLE archived_head_to_head;
+			DROP TABLE archived_results`,
+	},
+}
+
+// sqliteMigrations mirror migrations with SQLite column types. Keep the two
+// lists in step.
+var sqliteMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_players",
+		Up: `CREATE TABLE players (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT UNIQUE NOT NULL,
+			wins INTEGER DEFAULT 0,
+			losses INTEGER DEFAULT 0,
+			draws INTEGER DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		Down: `DROP TABLE players`,
+	},
+	{
+		Version: 2,
+		Name:    "create_games",
+		Up: `CREATE TABLE games (
+			id TEXT PRIMARY KEY,
+			player1 TEXT,
+			player2 TEXT,
+			winner TEXT,
+			is_bot BOOLEAN DEFAULT false,
+			status TEXT,
+			board_state TEXT,
+			created_at TIMESTAMP,
+			updated_at TIMESTAMP,
+			duration_seconds INTEGER
+		)`,
+		Down: `DROP TABLE games`,
+	},
+	{
+		Version: 3,
+		Name:    "index_games",
+		Up: `CREATE INDEX idx_games_player1 ON games(player1);
+			CREATE INDEX idx_games_player2 ON games(player2);
+			CREATE INDEX idx_games_winner ON games(winner);
+			CREATE INDEX idx_games_created_at ON games(created_at)`,
+		Down: `DROP INDEX idx_games_player1;
+			DROP INDEX idx_games_player2;
+			DROP INDEX idx_games_winner;
+			DROP INDEX idx_games_created_at`,
+	},
+	{
+		Version: 4,
+		Name:    "create_moves",
+		Up: `CREATE TABLE moves (
+			game_id TEXT NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+			move_number INTEGER NOT NULL,
+			player INTEGER NOT NULL,
+			col INTEGER NOT NULL,
+			row_num INTEGER NOT NULL,
+			played_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (game_id, move_number)
+		)`,
+		Down: `DROP TABLE moves`,
+	},
+	{
+		Version: 5,
+		Name:    "add_player_rating",
+		Up:      `ALTER TABLE players ADD COLUMN rating INTEGER NOT NULL DEFAULT 1200`,
+		Down:    `ALTER TABLE players DROP COLUMN rating`,
+	},
+	{
+		Version: 6,
+		Name:    "create_achievements",
+		Up: `CREATE TABLE achievements (
+			username TEXT NOT NULL,
+			achievement_id TEXT NOT NULL,
+			unlocked_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (username, achievement_id)
+		)`,
+		Down: `DROP TABLE achievements`,
+	},
+	{
+		Version: 7,
+		Name:    "add_player_profile",
+		Up: `ALTER TABLE players ADD COLUMN display_name TEXT;
+			ALTER TABLE players ADD COLUMN avatar TEXT;
+			ALTER TABLE players ADD COLUMN country TEXT;
+			ALTER TABLE players ADD COLUMN bot_difficulty TEXT;
+			ALTER TABLE players ADD COLUMN board_variant TEXT`,
+		Down: `ALTER TABLE players DROP COLUMN board_variant;
+			ALTER TABLE players DROP COLUMN bot_difficulty;
+			ALTER TABLE players DROP COLUMN country;
+			ALTER TABLE players DROP COLUMN avatar;
+			ALTER TABLE players DROP COLUMN display_name`,
+	},
+	{
+		Version: 8,
+		Name:    "create_outbox",
+		Up: `CREATE TABLE outbox (
+				id TEXT PRIMARY KEY,
+				game_id TEXT NOT NULL,
+				event_type TEXT NOT NULL,
+				payload TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				delivered_at TIMESTAMP
+			);
+			CREATE INDEX idx_outbox_pending ON outbox(created_at) WHERE delivered_at IS NULL`,
+		Down: `DROP TABLE outbox`,
+	},
+	{
+		Version: 9,
+		Name:    "create_player_tokens",
+		Up: `CREATE TABLE player_tokens (
+			username TEXT PRIMARY KEY,
+			token_hash TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		Down: `DROP TABLE player_tokens`,
+	},
+	{
+		Version: 10,
+		Name:    "create_archived_totals",
+		Up: `CREATE TABLE archived_results (
+				username TEXT PRIMARY KEY,
+				games INTEGER NOT NULL DEFAULT 0,
+				wins INTEGER NOT NULL DEFAULT 0,
+				draws INTEGER NOT NULL DEFAULT 0,
+				bot_games INTEGER NOT NULL DEFAULT 0,
+				bot_wins INTEGER NOT NULL DEFAULT 0,
+				bot_draws INTEGER NOT NULL DEFAULT 0
+			);
+			CREATE TABLE archived_head_to_head (
+				player_a TEXT NOT NULL,
+				player_b TEXT NOT NULL,
+				games INTEGER NOT NULL DEFAULT 0,
+				wins_a INTEGER NOT NULL DEFAULT 0,
+				wins_b INTEGER NOT NULL DEFAULT 0,
+				draws INTEGER NOT NULL DEFAULT 0,
+				streak_a INTEGER NOT NULL DEFAULT 0,
+				streak_b INTEGER NOT NULL DEFAULT 0,
+				longest_a INTEGER NOT NULL DEFAULT 0,
+				longest_b INTEGER NOT NULL DEFAULT 0,
+				duration_seconds INTEGER NOT NULL DEFAULT 0,
+				moves INTEGER NOT NULL DEFAULT 0,
+				first_mover_wins_a INTEGER NOT NULL DEFAULT 0,
+				first_mover_wins_b INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (player_a, player_b)
+			)`,
+		Down: `DROP TABLE archived_head_to_head;
+			DROP TABLE archived_results`,
+	},
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator applies and rolls back migrations, recording them in
+// schema_migrations. Each migration runs in its own transaction, and a whole
+// run holds an advisory lock so concurrent instances don't race.
+type Migrator struct {
+	db           *sql.DB
+	migrations   []Migration
+	advisoryLock bool
+}
+
+// NewMigrator returns a migrator for the Postgres schema.
+func NewMigrator(db *sql.DB) *Migrator {
+	return &Migrator{db: db, migrations: migrations, advisoryLock: true}
+}
+
+// NewSQLiteMigrator returns a migrator for the SQLite schema. SQLite databases
+// are local to one process, so no lock is taken.
+func NewSQLiteMigrator(db *sql.DB) *Migrator {
+	return &Migrator{db: db, migrations: sqliteMigrations}
+}
+
+// withLock runs fn on a single connection holding the migration lock, after
+// making sure schema_migrations exists.
+func (m *Migrator) withLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.advisoryLock {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+		defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	return fn(ctx, conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Up applies every pending migration up to and including target. A target of
+// 0 means the latest version.
+func (m *Migrator) Up(target int) error {
+	return m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if target > 0 && mig.Version > target {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			err := runInTx(ctx, conn, mig.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mig.Version, mig.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d (%s): %w", mig.Version, mig.Name, err)
+			}
+			log.Printf("Applied migration %d: %s\n", mig.Version, mig.Name)
+		}
+		return nil
+	})
+}
+
+// Down rolls back the most recently applied steps migrations.
+func (m *Migrator) Down(steps int) error {
+	return m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+
+			err := runInTx(ctx, conn, mig.Down, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+			if err != nil {
+				return fmt.Errorf("rolling back migration %d (%s): %w", mig.Version, mig.Name, err)
+			}
+			log.Printf("Rolled back migration %d: %s\n", mig.Version, mig.Name)
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration and when it was applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			status := MigrationStatus{Version: mig.Version, Name: mig.Name}
+			if at, ok := applied[mig.Version]; ok {
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// runInTx executes a migration's statements and its bookkeeping query
+// atomically.
+func runInTx(ctx context.Context, conn *sql.Conn, statements string, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RunMigrateCommand implements `backend migrate up [version]`,
+// `backend migrate down [steps]` and `backend migrate status` for the
+// configured SQL store.
+func RunMigrateCommand(cfg StoreConfig, args []string) error {
+	var migrator *Migrator
+	switch cfg.Kind {
+	case "postgres":
+		conn, err := OpenDB(cfg.DatabaseURL)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		migrator = NewMigrator(conn)
+	case "sqlite":
+		conn, err := openSQLite(cfg.SQLitePath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		migrator = NewSQLiteMigrator(conn)
+	default:
+		return fmt.Errorf("store %q has no schema to migrate", cfg.Kind)
+	}
+
+	var err error
+
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	n := 0
+	if len(args) > 1 {
+		n, err = strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid number %q", args[1])
+		}
+	}
+
+	switch action {
+	case "up":
+		return migrator.Up(n)
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		return migrator.Down(n)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-30s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate action %q (want up, down or status)", action)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestMigrationListsMatch(t *testing.T) {
+	if len(migrations) != len(sqliteMigrations) {
+		t.Fatalf("%d Postgres migrations but %d SQLite ones", len(migrations), len(sqliteMigrations))
+	}
+	for i, mig := range migrations {
+		lite := sqliteMigrations[i]
+		if mig.Version != i+1 || lite.Version != mig.Version || lite.Name != mig.Name {
+			t.Fatalf("migration %d is %d %s in Postgres and %d %s in SQLite", i+1, mig.Version, mig.Name, lite.Version, lite.Name)
+		}
+		if mig.Up == "" || mig.Down == "" || lite.Up == "" || lite.Down == "" {
+			t.Fatalf("migration %d (%s) is missing an up or down step", mig.Version, mig.Name)
+		}
+	}
+}
+
+// TestMigrateUpAndDown walks the SQLite schema up part of the way, to the
+// latest version, all the way down and back up again.
+func TestMigrateUpAndDown(t *testing.T) {
+	conn, err := openSQLite(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	m := NewSQLiteMigrator(conn)
+	latest := sqliteMigrations[len(sqliteMigrations)-1].Version
+
+	steps := []struct {
+		name    string
+		run     func() error
+		applied int
+	}{
+		{"up to 3", func() error { return m.Up(3) }, 3},
+		{"up to latest", func() error { return m.Up(0) }, latest},
+		{"up again", func() error { return m.Up(0) }, latest},
+		{"down 2", func() error { return m.Down(2) }, latest - 2},
+		{"down the rest", func() error { return m.Down(latest) }, 0},
+		{"up from scratch", func() error { return m.Up(0) }, latest},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		statuses, err := m.Status()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range statuses {
+			if want := s.Version <= step.applied; (s.AppliedAt != nil) != want {
+				t.Fatalf("%s: migration %d applied = %v, want %v", step.name, s.Version, s.AppliedAt != nil, want)
+			}
+		}
+		if step.applied == 0 {
+			if tables := sqliteTables(t, conn); !reflect.DeepEqual(tables, []string{"schema_migrations"}) {
+				t.Fatalf("%s: tables left = %v", step.name, tables)
+			}
+		}
+	}
+
+	// The schema the migrations build is the one the store runs on
+	store := &SQLiteStore{Database: &Database{conn: conn}}
+	if _, err := store.RecordResult(finishedGame("g1", "alice", "bob", "alice", testStart, 7)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetLeaderboard(LeaderboardQuery{SortBy: "rating", Limit: 10}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sqliteTables(t *testing.T, conn *sql.DB) []string {
+	t.Helper()
+	rows, err := conn.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	return tables
+}
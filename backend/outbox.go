@@ -0,0 +1,186 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var outboxStats = expvar.NewMap("outbox")
+
+// OutboxEvent is an event waiting in the outbox table. Its ID is the event's
+// EventID, so redelivering it after a crash produces the same event.
+type OutboxEvent struct {
+	ID    string
+	Event GameEvent
+}
+
+// OutboxConfig tunes the relay.
+type OutboxConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	Retention    time.Duration // how long delivered rows are kept
+}
+
+func LoadOutboxConfig() OutboxConfig {
+	return OutboxConfig{
+		PollInterval: envDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		BatchSize:    envInt("OUTBOX_BATCH_SIZE", 100),
+		Retention:    envDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+	}
+}
+
+// OutboxRelay publishes events written to the outbox with game results and
+// marks them delivered. An event is only marked after the sink accepted it,
+// so delivery is at least once: a crash in between sends it again with the
+// same ID.
+type OutboxRelay struct {
+	db   Store
+	sink EventSink
+	open func() (EventSink, error) // opens sink if it's nil; the relay then owns it
+	cfg  OutboxConfig
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewOutboxRelay relays to sink directly rather than through AsyncPublisher:
+// rows must not be marked delivered while events are merely queued.
+func NewOutboxRelay(db Store, sink EventSink, cfg OutboxConfig) *OutboxRelay {
+	return &OutboxRelay{
+		db:   db,
+		sink: sink,
+		cfg:  cfg,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// NewDeferredOutboxRelay is for a sink that couldn't be opened at startup.
+// Run keeps trying open, with the same backoff as failed deliveries, and
+// events stay pending until it succeeds. The relay closes the sink it opened
+// when it stops.
+func NewDeferredOutboxRelay(db Store, open func() (EventSink, error), cfg OutboxConfig) *OutboxRelay {
+	r := NewOutboxRelay(db, nil, cfg)
+	r.open = open
+	return r
+}
+
+// Run relays pending events every PollInterval until Stop is called, backing
+// off while the sink keeps failing.
+func (r *OutboxRelay) Run() {
+	defer close(r.done)
+	defer func() {
+		if r.open != nil && r.sink != nil {
+			r.sink.Close()
+		}
+	}()
+
+	lastPrune := time.Time{}
+	backoff := r.cfg.PollInterval
+	for {
+		wait := r.cfg.PollInterval
+		if err := r.openSink(); err != nil {
+			log.Printf("Error opening event sink for outbox: %v\n", err)
+			backoff *= 2
+			if backoff > time.Minute {
+				backoff = time.Minute
+			}
+			wait = backoff
+		} else if err := r.RelayPending(); err != nil {
+			log.Printf("Error relaying outbox: %v\n", err)
+			backoff *= 2
+			if backoff > time.Minute {
+				backoff = time.Minute
+			}
+			wait = backoff
+		} else {
+			backoff = r.cfg.PollInterval
+		}
+
+		if time.Since(lastPrune) > time.Hour {
+			if n, err := r.db.DeleteDeliveredEvents(time.Now().Add(-r.cfg.Retention)); err != nil {
+				log.Printf("Error pruning outbox: %v\n", err)
+			} else if n > 0 {
+				log.Printf("Pruned %d delivered outbox events\n", n)
+			}
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// openSink opens the sink of a deferred relay if it isn't open yet.
+func (r *OutboxRelay) openSink() error {
+	if r.sink != nil {
+		return nil
+	}
+	sink, err := r.open()
+	if err != nil {
+		return err
+	}
+	log.Println("Event sink opened, relaying outbox")
+	r.sink = sink
+	return nil
+}
+
+// Stop makes Run return after its current pass.
+func (r *OutboxRelay) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+// RelayPending publishes pending events, oldest first, until none are left.
+func (r *OutboxRelay) RelayPending() error {
+	if r.sink == nil {
+		return fmt.Errorf("event sink not open")
+	}
+	for {
+		pending, err := r.db.PendingEvents(r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		events := make([]GameEvent, len(pending))
+		ids := make([]string, len(pending))
+		for i, p := range pending {
+			events[i] = p.Event
+			ids[i] = p.ID
+		}
+		stampPublished(events)
+
+		if bs, ok := r.sink.(BatchSink); ok {
+			err = bs.PublishBatch(events)
+		} else {
+			for _, event := range events {
+				if err = r.sink.Publish(event); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			outboxStats.Add("failures", 1)
+			return err
+		}
+
+		if err := r.db.MarkDelivered(ids); err != nil {
+			return err
+		}
+		outboxStats.Add("delivered", int64(len(ids)))
+
+		if len(pending) < r.cfg.BatchSize {
+			return nil
+		}
+	}
+}
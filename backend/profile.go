@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxDisplayNameLength = 32
+	profileRecentGames   = 10
+)
+
+// Choices accepted for profile fields. The board is always the classic 7x6
+// grid for now; the variant preference is stored so clients can offer more
+// later without another migration.
+var (
+	avatars         = []string{"robot", "cat", "fox", "owl", "panda", "dragon", "rocket", "star"}
+	botDifficulties = []string{"easy", "medium", "hard"}
+	boardVariants   = []string{"classic"}
+
+	countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// Profile is what a player chooses to show about themselves. Empty display
+// name and avatar mean "use the username" and "no avatar".
+type Profile struct {
+	Username    string      `json:"username"`
+	DisplayName string      `json:"displayName"`
+	Avatar      string      `json:"avatar"`
+	Country     string      `json:"country"` // ISO 3166-1 alpha-2, or empty
+	Preferences Preferences `json:"preferences"`
+}
+
+type Preferences struct {
+	BotDifficulty string `json:"botDifficulty"`
+	BoardVariant  string `json:"boardVariant"`
+}
+
+// ProfileUpdate is the body of a profile update. Omitted fields keep their
+// current value.
+type ProfileUpdate struct {
+	DisplayName *string `json:"displayName"`
+	Avatar      *string `json:"avatar"`
+	Country     *string `json:"country"`
+	Preferences *struct {
+		BotDifficulty *string `json:"botDifficulty"`
+		BoardVariant  *string `json:"boardVariant"`
+	} `json:"preferences"`
+}
+
+// defaultProfile is the profile of a player who never set one.
+func defaultProfile(username string) *Profile {
+	return &Profile{
+		Username: username,
+		Preferences: Preferences{
+			BotDifficulty: "medium",
+			BoardVariant:  "classic",
+		},
+	}
+}
+
+// withDefaults fills preferences that were never set.
+func (p *Profile) withDefaults() *Profile {
+	defaults := defaultProfile(p.Username)
+	if p.Preferences.BotDifficulty == "" {
+		p.Preferences.BotDifficulty = defaults.Preferences.BotDifficulty
+	}
+	if p.Preferences.BoardVariant == "" {
+		p.Preferences.BoardVariant = defaults.Preferences.BoardVariant
+	}
+	return p
+}
+
+// Apply copies the fields present in u onto p.
+func (u *ProfileUpdate) Apply(p *Profile) {
+	if u.DisplayName != nil {
+		p.DisplayName = strings.TrimSpace(*u.DisplayName)
+	}
+	if u.Avatar != nil {
+		p.Avatar = *u.Avatar
+	}
+	if u.Country != nil {
+		p.Country = strings.ToUpper(strings.TrimSpace(*u.Country))
+	}
+	if u.Preferences != nil {
+		if u.Preferences.BotDifficulty != nil {
+			p.Preferences.BotDifficulty = *u.Preferences.BotDifficulty
+		}
+		if u.Preferences.BoardVariant != nil {
+			p.Preferences.BoardVariant = *u.Preferences.BoardVariant
+		}
+	}
+}
+
+// Validate returns the first problem with p, or nil.
+func (p *Profile) Validate() error {
+	if utf8.RuneCountInString(p.DisplayName) > maxDisplayNameLength {
+		return fmt.Errorf("displayName must be at most %d characters", maxDisplayNameLength)
+	}
+	for _, r := range p.DisplayName {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf("displayName contains invalid characters")
+		}
+	}
+	if p.Avatar != "" && !contains(avatars, p.Avatar) {
+		return fmt.Errorf("avatar must be one of %s", strings.Join(avatars, ", "))
+	}
+	if p.Country != "" && !countryCode.MatchString(p.Country) {
+		return fmt.Errorf("country must be a two-letter ISO 3166-1 code")
+	}
+	if !contains(botDifficulties, p.Preferences.BotDifficulty) {
+		return fmt.Errorf("botDifficulty must be one of %s", strings.Join(botDifficulties, ", "))
+	}
+	if !contains(boardVariants, p.Preferences.BoardVariant) {
+		return fmt.Errorf("boardVariant must be one of %s", strings.Join(boardVariants, ", "))
+	}
+	return nil
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) updateProfile(c *gin.Context) {
+	username := c.Param("username")
+	if !s.authorizePlayer(c, username) {
+		return
+	}
+
+	var update ProfileUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid profile"})
+		return
+	}
+
+	profile, err := s.db.GetProfile(username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+
+	update.Apply(profile)
+	if err := profile.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.db.SaveProfile(profile); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// getPreferences returns a player's preferences, to that player only.
+func (s *Server) getPreferences(c *gin.Context) {
+	username := c.Param("username")
+	if !s.authorizePlayer(c, username) {
+		return
+	}
+
+	profile, err := s.db.GetProfile(username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile.Preferences)
+}
+
+// getPublicProfile combines everything shown on a player's page.
+func (s *Server) getPublicProfile(c *gin.Context) {
+	username := c.Param("username")
+
+	profile, err := s.db.GetProfile(username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch profile"})
+		return
+	}
+
+	stats, err := s.db.GetPlayerStats(username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch player stats"})
+		return
+	}
+
+	achievements, err := GetAchievements(s.db, username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+
+	recentGames, err := s.db.ListPlayerGames(GameQuery{Username: username, Limit: profileRecentGames})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch games"})
+		return
+	}
+
+	// Preferences are the player's own business
+	c.JSON(http.StatusOK, gin.H{
+		"profile": gin.H{
+			"username":    profile.Username,
+			"displayName": profile.DisplayName,
+			"avatar":      profile.Avatar,
+			"country":     profile.Country,
+		},
+		"stats":        stats,
+		"achievements": achievements,
+		"recentGames":  recentGames,
+	})
+}
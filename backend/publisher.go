@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var publisherStats = expvar.NewMap("event_publisher")
+
+var errPublisherClosed = errors.New("event publisher closed")
+
+// BatchSink is an EventSink that can write several events at once. The
+// publisher uses it when available instead of one Publish per event.
+type BatchSink interface {
+	EventSink
+	PublishBatch(events []GameEvent) error
+}
+
+// PublisherConfig tunes the background publisher.
+type PublisherConfig struct {
+	BufferSize      int           // events queued before new ones are dropped
+	BatchSize       int           // most events sent in one write
+	FlushInterval   time.Duration // longest an event waits for its batch to fill
+	MaxRetries      int           // retries per batch before it is dropped
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+	ShutdownTimeout time.Duration // how long Close may spend flushing
+}
+
+func LoadPublisherConfig() PublisherConfig {
+	return PublisherConfig{
+		BufferSize:      envInt("EVENT_BUFFER_SIZE", 10000),
+		BatchSize:       envInt("EVENT_BATCH_SIZE", 100),
+		FlushInterval:   envDuration("EVENT_FLUSH_INTERVAL", time.Second),
+		MaxRetries:      envInt("EVENT_MAX_RETRIES", 5),
+		InitialBackoff:  envDuration("EVENT_RETRY_BACKOFF", 200*time.Millisecond),
+		MaxBackoff:      envDuration("EVENT_RETRY_MAX_BACKOFF", 10*time.Second),
+		ShutdownTimeout: envDuration("EVENT_SHUTDOWN_TIMEOUT", 10*time.Second),
+	}
+}
+
+// Validate reports settings the publisher can't run with, naming the
+// environment variable to fix.
+func (cfg PublisherConfig) Validate() error {
+	switch {
+	case cfg.BufferSize <= 0:
+		return fmt.Errorf("EVENT_BUFFER_SIZE must be positive, got %d", cfg.BufferSize)
+	case cfg.BatchSize <= 0:
+		return fmt.Errorf("EVENT_BATCH_SIZE must be positive, got %d", cfg.BatchSize)
+	case cfg.FlushInterval <= 0:
+		return fmt.Errorf("EVENT_FLUSH_INTERVAL must be positive, got %s", cfg.FlushInterval)
+	case cfg.MaxRetries < 0:
+		return fmt.Errorf("EVENT_MAX_RETRIES must not be negative, got %d", cfg.MaxRetries)
+	case cfg.InitialBackoff <= 0:
+		return fmt.Errorf("EVENT_RETRY_BACKOFF must be positive, got %s", cfg.InitialBackoff)
+	case cfg.MaxBackoff < cfg.InitialBackoff:
+		return fmt.Errorf("EVENT_RETRY_MAX_BACKOFF must be at least EVENT_RETRY_BACKOFF, got %s", cfg.MaxBackoff)
+	case cfg.ShutdownTimeout <= 0:
+		return fmt.Errorf("EVENT_SHUTDOWN_TIMEOUT must be positive, got %s", cfg.ShutdownTimeout)
+	}
+	return nil
+}
+
+// AsyncPublisher is an EventSink that queues events and writes them to the
+// underlying sink in batches from a background goroutine, so Publish never
+// waits on the broker. When the queue is full, events are dropped and counted
+// rather than blocking gameplay.
+type AsyncPublisher struct {
+	sink  EventSink
+	cfg   PublisherConfig
+	queue chan GameEvent
+
+	mu     sync.RWMutex // guards closed against Publish racing Close
+	closed bool
+	done   chan struct{}
+	stop   chan struct{} // closed when the shutdown deadline passes
+}
+
+func NewAsyncPublisher(sink EventSink, cfg PublisherConfig) *AsyncPublisher {
+	p := &AsyncPublisher{
+		sink:  sink,
+		cfg:   cfg,
+		queue: make(chan GameEvent, cfg.BufferSize),
+		done:  make(chan struct{}),
+		stop:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Publish queues event. It only fails if the publisher is closed; a full queue
+// drops the event and counts it under dropped_queue_full.
+func (p *AsyncPublisher) Publish(event GameEvent) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		publisherStats.Add("dropped_closed", 1)
+		return errPublisherClosed
+	}
+
+	select {
+	case p.queue <- event:
+		publisherStats.Add("queued", 1)
+	default:
+		publisherStats.Add("dropped_queue_full", 1)
+	}
+	return nil
+}
+
+// Close stops accepting events, flushes the queue within ShutdownTimeout and
+// closes the underlying sink. Events still unsent at the deadline are dropped.
+func (p *AsyncPublisher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+	case <-time.After(p.cfg.ShutdownTimeout):
+		close(p.stop)
+		<-p.done
+		log.Println("Event publisher shutdown timed out; remaining events dropped")
+	}
+	return p.sink.Close()
+}
+
+func (p *AsyncPublisher) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]GameEvent, 0, p.cfg.BatchSize)
+	for {
+		select {
+		case event, ok := <-p.queue:
+			if !ok {
+				p.send(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= p.cfg.BatchSize {
+				p.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.send(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// send writes one batch, retrying with exponential backoff. A batch that still
+// fails after MaxRetries, or when shutdown runs out of time, is dropped.
+func (p *AsyncPublisher) send(batch []GameEvent) {
+	select {
+	case <-p.stop:
+		publisherStats.Add("dropped_shutdown", int64(len(batch)))
+		return
+	default:
+	}
+
+	backoff := p.cfg.InitialBackoff
+	for attempt := 0; len(batch) > 0; attempt++ {
+		remaining, err := p.write(batch)
+		publisherStats.Add("published", int64(len(batch)-len(remaining)))
+		if err == nil {
+			publisherStats.Add("batches", 1)
+			return
+		}
+		batch = remaining
+
+		if attempt >= p.cfg.MaxRetries {
+			log.Printf("Dropping %d events after %d retries: %v\n", len(batch), attempt, err)
+			publisherStats.Add("dropped_retries", int64(len(batch)))
+			return
+		}
+
+		log.Printf("Publishing %d events failed, retrying in %s: %v\n", len(batch), backoff, err)
+		publisherStats.Add("retries", 1)
+		select {
+		case <-time.After(backoff):
+		case <-p.stop:
+			publisherStats.Add("dropped_shutdown", int64(len(batch)))
+			return
+		}
+
+		backoff *= 2
+		if backoff > p.cfg.MaxBackoff {
+			backoff = p.cfg.MaxBackoff
+		}
+	}
+}
+
+// write sends batch and returns the events that didn't go out.
+func (p *AsyncPublisher) write(batch []GameEvent) ([]GameEvent, error) {
+	stampPublished(batch)
+	if bs, ok := p.sink.(BatchSink); ok {
+		if err := bs.PublishBatch(batch); err != nil {
+			return batch, err
+		}
+		return nil, nil
+	}
+	for i, event := range batch {
+		if err := p.sink.Publish(event); err != nil {
+			return batch[i:], err
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublisherConfigValidate(t *testing.T) {
+	valid := PublisherConfig{
+		BufferSize:      10,
+		BatchSize:       5,
+		FlushInterval:   time.Second,
+		MaxRetries:      0,
+		InitialBackoff:  time.Millisecond,
+		MaxBackoff:      time.Second,
+		ShutdownTimeout: time.Second,
+	}
+	tests := []struct {
+		name    string
+		change  func(*PublisherConfig)
+		wantErr string
+	}{
+		{"valid", func(*PublisherConfig) {}, ""},
+		{"empty buffer", func(c *PublisherConfig) { c.BufferSize = 0 }, "EVENT_BUFFER_SIZE"},
+		{"empty batch", func(c *PublisherConfig) { c.BatchSize = 0 }, "EVENT_BATCH_SIZE"},
+		{"negative batch", func(c *PublisherConfig) { c.BatchSize = -1 }, "EVENT_BATCH_SIZE"},
+		{"no flush interval", func(c *PublisherConfig) { c.FlushInterval = 0 }, "EVENT_FLUSH_INTERVAL"},
+		{"negative retries", func(c *PublisherConfig) { c.MaxRetries = -1 }, "EVENT_MAX_RETRIES"},
+		{"no backoff", func(c *PublisherConfig) { c.InitialBackoff = 0 }, "EVENT_RETRY_BACKOFF"},
+		{"max backoff below initial", func(c *PublisherConfig) { c.MaxBackoff = time.Microsecond }, "EVENT_RETRY_MAX_BACKOFF"},
+		{"no shutdown timeout", func(c *PublisherConfig) { c.ShutdownTimeout = 0 }, "EVENT_SHUTDOWN_TIMEOUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.change(&cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+	if err := LoadPublisherConfig().Validate(); err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+}
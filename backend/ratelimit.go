@@ -0,0 +1,321 @@
+package main
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Buckets that haven't been touched for this long are forgotten.
+const bucketIdleTTL = 10 * time.Minute
+
+var abuseViolations = expvar.NewMap("abuse_violations")
+
+// AbuseConfig holds the rate limits and ban policy. Rates are tokens per
+// second; bursts are bucket sizes.
+type AbuseConfig struct {
+	ConnMessageRate  float64
+	ConnMessageBurst int
+	UserMessageRate  float64
+	UserMessageBurst int
+	IPMessageRate    float64
+	IPMessageBurst   int
+	APIRate          float64
+	APIBurst         int
+	MaxConnsPerIP    int
+	MaxFrameBytes    int64
+	BanThreshold     int // violations within BanWindow that trigger a ban
+	BanWindow        time.Duration
+	BanDuration      time.Duration
+	TrustedProxies   []string // proxies whose X-Forwarded-For is believed; none by default
+}
+
+// LoadAbuseConfig reads the limits from the environment, falling back to
+// defaults suitable for normal play.
+func LoadAbuseConfig() AbuseConfig {
+	return AbuseConfig{
+		ConnMessageRate:  envFloat("RATE_LIMIT_CONN_PER_SEC", 5),
+		ConnMessageBurst: envInt("RATE_LIMIT_CONN_BURST", 10),
+		UserMessageRate:  envFloat("RATE_LIMIT_USER_PER_SEC", 5),
+		UserMessageBurst: envInt("RATE_LIMIT_USER_BURST", 10),
+		IPMessageRate:    envFloat("RATE_LIMIT_IP_PER_SEC", 20),
+		IPMessageBurst:   envInt("RATE_LIMIT_IP_BURST", 40),
+		APIRate:          envFloat("RATE_LIMIT_API_PER_SEC", 10),
+		APIBurst:         envInt("RATE_LIMIT_API_BURST", 30),
+		MaxConnsPerIP:    envInt("MAX_CONNECTIONS_PER_IP", 10),
+		MaxFrameBytes:    int64(envInt("MAX_FRAME_BYTES", 4096)),
+		BanThreshold:     envInt("BAN_THRESHOLD", 20),
+		BanWindow:        envDuration("BAN_WINDOW", time.Minute),
+		BanDuration:      envDuration("BAN_DURATION", 10*time.Minute),
+		TrustedProxies:   envList("TRUSTED_PROXIES"),
+	}
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// envList reads a comma-separated list, skipping empty entries.
+func envList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func envFloat(key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a set of token buckets keyed by connection, username or IP.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		swept:   time.Now(),
+	}
+}
+
+// Allow takes a token from key's bucket, reporting false if it's empty.
+func (rl *RateLimiter) Allow(key string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(rl.swept) > bucketIdleTTL {
+		for k, b := range rl.buckets {
+			if now.Sub(b.last) > bucketIdleTTL {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.swept = now
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * rl.rate
+	if b.tokens > rl.burst {
+		b.tokens = rl.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (rl *RateLimiter) Forget(key string) {
+	rl.mu.Lock()
+	delete(rl.buckets, key)
+	rl.mu.Unlock()
+}
+
+// AbuseGuard applies the rate limits to WebSocket messages, fallback transport
+// sessions and the REST API, and temporarily bans IPs that keep violating them.
+type AbuseGuard struct {
+	cfg   AbuseConfig
+	conns *RateLimiter
+	users *RateLimiter
+	ips   *RateLimiter
+	api   *RateLimiter
+
+	mu      sync.Mutex
+	open    map[string]int         // open connections per IP
+	strikes map[string][]time.Time // recent violations per IP
+	bans    map[string]time.Time   // IP -> banned until
+	swept   time.Time
+}
+
+func NewAbuseGuard(cfg AbuseConfig) *AbuseGuard {
+	return &AbuseGuard{
+		cfg:     cfg,
+		conns:   NewRateLimiter(cfg.ConnMessageRate, cfg.ConnMessageBurst),
+		users:   NewRateLimiter(cfg.UserMessageRate, cfg.UserMessageBurst),
+		ips:     NewRateLimiter(cfg.IPMessageRate, cfg.IPMessageBurst),
+		api:     NewRateLimiter(cfg.APIRate, cfg.APIBurst),
+		open:    make(map[string]int),
+		strikes: make(map[string][]time.Time),
+		bans:    make(map[string]time.Time),
+		swept:   time.Now(),
+	}
+}
+
+// Banned reports whether ip is currently banned.
+func (g *AbuseGuard) Banned(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	until, ok := g.bans[ip]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(g.bans, ip)
+		return false
+	}
+	return true
+}
+
+// OpenConnection reserves a connection slot for ip. Every successful call must
+// be paired with CloseConnection.
+func (g *AbuseGuard) OpenConnection(ip string) bool {
+	if g.Banned(ip) {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.open[ip] >= g.cfg.MaxConnsPerIP {
+		g.strikeLocked("connections_per_ip", ip)
+		return false
+	}
+	g.open[ip]++
+	return true
+}
+
+func (g *AbuseGuard) CloseConnection(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.open[ip]--
+	if g.open[ip] <= 0 {
+		delete(g.open, ip)
+	}
+}
+
+// AllowMessage checks a client message against the per-connection,
+// per-username and per-IP limits.
+func (g *AbuseGuard) AllowMessage(client *Client, ip string) bool {
+	if g.Banned(ip) {
+		return false
+	}
+
+	if !g.conns.Allow(client.id) {
+		g.strike("connection_messages", ip)
+		return false
+	}
+	if client.username != "" && !g.users.Allow(client.username) {
+		g.strike("user_messages", ip)
+		return false
+	}
+	if !g.ips.Allow(ip) {
+		g.strike("ip_messages", ip)
+		return false
+	}
+	return true
+}
+
+// ForgetClient drops the per-connection bucket of a closed client.
+func (g *AbuseGuard) ForgetClient(client *Client) {
+	g.conns.Forget(client.id)
+}
+
+func (g *AbuseGuard) strike(kind string, ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.strikeLocked(kind, ip)
+}
+
+func (g *AbuseGuard) strikeLocked(kind string, ip string) {
+	abuseViolations.Add(kind, 1)
+
+	now := time.Now()
+	if now.Sub(g.swept) > bucketIdleTTL {
+		g.sweepLocked(now)
+	}
+
+	recent := g.strikes[ip][:0]
+	for _, t := range g.strikes[ip] {
+		if now.Sub(t) < g.cfg.BanWindow {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	g.strikes[ip] = recent
+
+	log.Printf("Rate limit violation (%s) from %s, %d in window\n", kind, ip, len(recent))
+
+	if len(recent) >= g.cfg.BanThreshold {
+		g.bans[ip] = now.Add(g.cfg.BanDuration)
+		delete(g.strikes, ip)
+		abuseViolations.Add("bans", 1)
+		log.Printf("Banned %s for %s\n", ip, g.cfg.BanDuration)
+	}
+}
+
+// sweepLocked forgets strikes that have left the ban window and bans that
+// have run out, so IPs that stop misbehaving don't stay in memory.
+func (g *AbuseGuard) sweepLocked(now time.Time) {
+	for ip, times := range g.strikes {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) >= g.cfg.BanWindow {
+			delete(g.strikes, ip)
+		}
+	}
+	for ip, until := range g.bans {
+		if now.After(until) {
+			delete(g.bans, ip)
+		}
+	}
+	g.swept = now
+}
+
+// Middleware limits REST requests per IP.
+func (g *AbuseGuard) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		if g.Banned(ip) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Temporarily banned"})
+			return
+		}
+		if !g.api.Allow(ip) {
+			g.strike("api_requests", ip)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
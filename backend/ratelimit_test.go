@@ -0,0 +1,134 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	rl := NewRateLimiter(0.001, 3)
+	for i := 0; i < 3; i++ {
+		if !rl.Allow("a") {
+			t.Fatalf("request %d refused within the burst", i+1)
+		}
+	}
+	if rl.Allow("a") {
+		t.Fatal("request beyond the burst allowed")
+	}
+	if !rl.Allow("b") {
+		t.Fatal("another key shares a's bucket")
+	}
+
+	rl.Forget("a")
+	if !rl.Allow("a") {
+		t.Fatal("forgotten key still limited")
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	rl := NewRateLimiter(2, 4)
+	for rl.Allow("a") {
+	}
+
+	// Half a second at 2 tokens a second refills one token
+	rl.buckets["a"].last = time.Now().Add(-500 * time.Millisecond)
+	if !rl.Allow("a") {
+		t.Fatal("refilled token refused")
+	}
+	if rl.Allow("a") {
+		t.Fatal("refilled more than one token")
+	}
+
+	// A long wait refills up to the burst, no more
+	rl.buckets["a"].last = time.Now().Add(-time.Hour)
+	for i := 0; i < 4; i++ {
+		if !rl.Allow("a") {
+			t.Fatalf("request %d refused after a full refill", i+1)
+		}
+	}
+	if rl.Allow("a") {
+		t.Fatal("bucket refilled beyond its burst")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	rl := NewRateLimiter(1, 1)
+	rl.Allow("idle")
+	rl.Allow("busy")
+	rl.buckets["idle"].last = time.Now().Add(-2 * bucketIdleTTL)
+	rl.swept = time.Now().Add(-2 * bucketIdleTTL)
+
+	rl.Allow("busy")
+	if _, ok := rl.buckets["idle"]; ok {
+		t.Fatal("idle bucket not swept")
+	}
+	if _, ok := rl.buckets["busy"]; !ok {
+		t.Fatal("busy bucket swept")
+	}
+}
+
+func TestAbuseGuardBans(t *testing.T) {
+	g := NewAbuseGuard(AbuseConfig{
+		MaxConnsPerIP: 1,
+		BanThreshold:  3,
+		BanWindow:     time.Minute,
+		BanDuration:   time.Minute,
+	})
+
+	if !g.OpenConnection("1.2.3.4") {
+		t.Fatal("first connection refused")
+	}
+	for i := 0; i < 2; i++ {
+		if g.OpenConnection("1.2.3.4") {
+			t.Fatal("connection beyond the limit allowed")
+		}
+	}
+	if g.Banned("1.2.3.4") {
+		t.Fatal("banned below the threshold")
+	}
+	if !g.OpenConnection("5.6.7.8") {
+		t.Fatal("another IP refused")
+	}
+
+	g.OpenConnection("1.2.3.4")
+	if !g.Banned("1.2.3.4") {
+		t.Fatal("not banned at the threshold")
+	}
+	g.CloseConnection("1.2.3.4")
+	if g.OpenConnection("1.2.3.4") {
+		t.Fatal("banned IP allowed to connect")
+	}
+
+	g.bans["1.2.3.4"] = time.Now().Add(-time.Second)
+	if g.Banned("1.2.3.4") || !g.OpenConnection("1.2.3.4") {
+		t.Fatal("ban didn't expire")
+	}
+}
+
+func TestAbuseGuardSweep(t *testing.T) {
+	g := NewAbuseGuard(AbuseConfig{
+		BanThreshold: 10,
+		BanWindow:    time.Minute,
+		BanDuration:  time.Minute,
+	})
+	now := time.Now()
+	g.strikes["stale"] = []time.Time{now.Add(-2 * time.Minute)}
+	g.strikes["recent"] = []time.Time{now.Add(-2 * time.Minute), now.Add(-time.Second)}
+	g.bans["expired"] = now.Add(-time.Second)
+	g.bans["active"] = now.Add(time.Minute)
+	g.swept = now.Add(-2 * bucketIdleTTL)
+
+	g.strike("api_requests", "other")
+	if _, ok := g.strikes["stale"]; ok {
+		t.Fatal("stale strikes not swept")
+	}
+	if _, ok := g.strikes["recent"]; !ok {
+		t.Fatal("recent strikes swept")
+	}
+	if _, ok := g.bans["expired"]; ok {
+		t.Fatal("expired ban not swept")
+	}
+	if _, ok := g.bans["active"]; !ok {
+		t.Fatal("active ban swept")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import "math"
+
+// Elo parameters. Bots don't have a stored rating; games against them count
+// as games against a fixed botRating.
+const (
+	initialRating = 1200
+	botRating     = 1200
+	ratingK       = 32
+)
+
+// eloRatings returns both players' new ratings after a game in which player 1
+// scored score1 (1 for a win, 0.5 for a draw, 0 for a loss).
+func eloRatings(r1 int, r2 int, score1 float64) (int, int) {
+	expected1 := 1 / (1 + math.Pow(10, float64(r2-r1)/400))
+	delta := int(math.Round(ratingK * (score1 - expected1)))
+	return r1 + delta, r2 - delta
+}
+
+// ratingScore is player 1's Elo score for a finished game, and false if the
+// game has no result to rate.
+func ratingScore(game *GameState) (float64, bool) {
+	switch game.Winner {
+	case "":
+		return 0, false
+	case "draw":
+		return 0.5, true
+	case game.Player1:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestEloRatings(t *testing.T) {
+	tests := []struct {
+		name         string
+		r1, r2       int
+		score1       float64
+		want1, want2 int
+	}{
+		{"equal, player 1 wins", 1200, 1200, 1, 1216, 1184},
+		{"equal, player 1 loses", 1200, 1200, 0, 1184, 1216},
+		{"equal draw", 1200, 1200, 0.5, 1200, 1200},
+		{"favourite wins", 1400, 1200, 1, 1408, 1192},
+		{"underdog wins", 1200, 1400, 1, 1224, 1376},
+		{"draw favours the underdog", 1400, 1200, 0.5, 1392, 1208},
+		{"far apart, favourite wins", 2000, 1000, 1, 2000, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got1, got2 := eloRatings(tt.r1, tt.r2, tt.score1)
+			if got1 != tt.want1 || got2 != tt.want2 {
+				t.Fatalf("eloRatings(%d, %d, %v) = %d, %d, want %d, %d", tt.r1, tt.r2, tt.score1, got1, got2, tt.want1, tt.want2)
+			}
+			if got1+got2 != tt.r1+tt.r2 {
+				t.Fatalf("ratings not conserved: %d + %d != %d + %d", got1, got2, tt.r1, tt.r2)
+			}
+		})
+	}
+}
+
+func TestRatingScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		winner string
+		want   float64
+		rated  bool
+	}{
+		{"player 1 wins", "alice", 1, true},
+		{"player 2 wins", "bob", 0, true},
+		{"bot wins", "Bot", 0, true},
+		{"draw", "draw", 0.5, true},
+		{"unfinished", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rated := ratingScore(&GameState{Player1: "alice", Player2: "bob", Winner: tt.winner})
+			if got != tt.want || rated != tt.rated {
+				t.Fatalf("ratingScore(winner %q) = %v, %v, want %v, %v", tt.winner, got, rated, tt.want, tt.rated)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const archiveBatchSize = 500
+
+// deletedPlayerPrefix starts the alias that replaces a deleted player's
+// username in their past games.
+const deletedPlayerPrefix = "deleted-"
+
+// RetentionConfig controls archiving of old games. A zero MaxAge disables it.
+type RetentionConfig struct {
+	MaxAge     time.Duration
+	ArchiveDir string
+	Interval   time.Duration
+}
+
+// LoadRetentionConfig reads RETENTION_DAYS, ARCHIVE_DIR and
+// RETENTION_INTERVAL.
+func LoadRetentionConfig() RetentionConfig {
+	cfg := RetentionConfig{
+		MaxAge:     time.Duration(envInt("RETENTION_DAYS", 0)) * 24 * time.Hour,
+		ArchiveDir: os.Getenv("ARCHIVE_DIR"),
+		Interval:   envDuration("RETENTION_INTERVAL", 24*time.Hour),
+	}
+	if cfg.ArchiveDir == "" {
+		cfg.ArchiveDir = "archive"
+	}
+	return cfg
+}
+
+// Validate reports settings retention can't run with. The interval only
+// matters when archiving is enabled.
+func (cfg RetentionConfig) Validate() error {
+	if cfg.MaxAge < 0 {
+		return fmt.Errorf("RETENTION_DAYS must not be negative, got %d", int(cfg.MaxAge/(24*time.Hour)))
+	}
+	if cfg.MaxAge > 0 && cfg.Interval <= 0 {
+		return fmt.Errorf("RETENTION_INTERVAL must be positive, got %s", cfg.Interval)
+	}
+	return nil
+}
+
+// ArchivedGame is one line of an archive file.
+type ArchivedGame struct {
+	Game  GameRecord `json:"game"`
+	Moves []Move     `json:"moves"`
+}
+
+// ArchiveOldGames moves finished games older than cfg.MaxAge out of the store,
+// a batch at a time. Each batch is written to its own gzipped NDJSON file and
+// only deleted once that file is safely on disk, so a crash can at worst
+// archive a batch twice. It returns how many games were archived.
+func ArchiveOldGames(db Store, cfg RetentionConfig) (int, error) {
+	if err := os.MkdirAll(cfg.ArchiveDir, 0o755); err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-cfg.MaxAge)
+	total := 0
+	for {
+		games, err := db.ListGamesBefore(cutoff, archiveBatchSize)
+		if err != nil {
+			return total, err
+		}
+		if len(games) == 0 {
+			return total, nil
+		}
+
+		path, err := writeArchive(cfg.ArchiveDir, games)
+		if err != nil {
+			return total, fmt.Errorf("writing archive: %w", err)
+		}
+
+		if err := db.DeleteArchivedGames(games); err != nil {
+			return total, fmt.Errorf("deleting archived games: %w", err)
+		}
+
+		total += len(games)
+		log.Printf("Archived %d games to %s\n", len(games), path)
+
+		if len(games) < archiveBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// writeArchive writes games to a new file in dir and returns its path.
+func writeArchive(dir string, games []ArchivedGame) (string, error) {
+	name := fmt.Sprintf("games-%s-%s.ndjson.gz", time.Now().UTC().Format("20060102T150405Z"), games[0].Game.ID)
+	path := filepath.Join(dir, name)
+	return path, writeArchiveFile(path, games)
+}
+
+// writeArchiveFile writes games to path through a temporary file, so path is
+// never left half written.
+func writeArchiveFile(path string, games []ArchivedGame) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	enc := json.NewEncoder(zw)
+	for _, g := range games {
+		if err := enc.Encode(g); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readArchive reads every game in an archive file.
+func readArchive(path string) ([]ArchivedGame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var games []ArchivedGame
+	dec := json.NewDecoder(zr)
+	for {
+		var g ArchivedGame
+		if err := dec.Decode(&g); err == io.EOF {
+			return games, nil
+		} else if err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+}
+
+// anonymizeArchives replaces username with alias in every archive file in dir
+// and returns how many archived games mentioned the player.
+func anonymizeArchives(dir string, username string, alias string) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "games-*.ndjson.gz"))
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, path := range paths {
+		games, err := readArchive(path)
+		if err != nil {
+			return total, fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		changed := 0
+		for i := range games {
+			if anonymizeGame(&games[i].Game, username, alias) {
+				changed++
+			}
+		}
+		if changed == 0 {
+			continue
+		}
+		if err := writeArchiveFile(path, games); err != nil {
+			return total, fmt.Errorf("rewriting %s: %w", path, err)
+		}
+		total += changed
+	}
+	return total, nil
+}
+
+// anonymizeGame replaces username with alias in game and reports whether it
+// appeared.
+func anonymizeGame(game *GameRecord, username string, alias string) bool {
+	found := false
+	for _, field := range []*string{&game.Player1, &game.Player2, &game.Winner} {
+		if *field == username {
+			*field = alias
+			found = true
+		}
+	}
+	return found
+}
+
+// anonymizeEvent replaces username with alias in event and reports whether it
+// appeared.
+func anonymizeEvent(event *GameEvent, username string, alias string) bool {
+	found := false
+	for _, field := range []*string{&event.Player, &event.Opponent, &event.GameResult} {
+		if *field == username {
+			*field = alias
+			found = true
+		}
+	}
+	return found
+}
+
+// RunRetention archives old games every cfg.Interval until the process exits.
+func RunRetention(db Store, cfg RetentionConfig) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := ArchiveOldGames(db, cfg); err != nil {
+			log.Printf("Error archiving games: %v\n", err)
+		}
+		<-ticker.C
+	}
+}
+
+// DeletePlayer removes a player's account. Their username is replaced by a
+// random alias in every game they played, in the store, in unsent outbox
+// events and in the archive files in archiveDir, so opponents keep their
+// history, stats and ratings. The alias is returned.
+func DeletePlayer(db Store, username string, archiveDir string) (string, error) {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	alias := deletedPlayerPrefix + hex.EncodeToString(suffix)
+
+	// The store goes first so games archived meanwhile already carry the alias
+	found, err := db.AnonymizePlayer(username, alias)
+	if err != nil {
+		return "", err
+	}
+	archived, err := anonymizeArchives(archiveDir, username, alias)
+	if err != nil {
+		return "", err
+	}
+	if !found && archived == 0 {
+		return "", fmt.Errorf("player %q not found", username)
+	}
+	return alias, nil
+}
+
+// RunDeletePlayerCommand implements `backend delete-player <username>`.
+func RunDeletePlayerCommand(db Store, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: delete-player <username>")
+	}
+
+	alias, err := DeletePlayer(db, args[0], LoadRetentionConfig().ArchiveDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Deleted %s; past games now show %s\n", args[0], alias)
+	fmt.Printf("Run `analytics delete-player %s %s` to rewrite the analytics database\n", args[0], alias)
+	return nil
+}
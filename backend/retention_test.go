@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetentionConfigValidate(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		name    string
+		cfg     RetentionConfig
+		wantErr string
+	}{
+		{"disabled", RetentionConfig{}, ""},
+		{"enabled", RetentionConfig{MaxAge: 30 * day, Interval: day}, ""},
+		{"disabled ignores the interval", RetentionConfig{Interval: 0}, ""},
+		{"negative age", RetentionConfig{MaxAge: -day, Interval: day}, "RETENTION_DAYS"},
+		{"no interval", RetentionConfig{MaxAge: 30 * day}, "RETENTION_INTERVAL"},
+		{"negative interval", RetentionConfig{MaxAge: 30 * day, Interval: -time.Hour}, "RETENTION_INTERVAL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error about %s", err, tt.wantErr)
+			}
+		})
+	}
+}
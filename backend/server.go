@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"log"
 	"net/http"
 
@@ -10,47 +12,54 @@ import (
 type Server struct {
 	port        string
 	hub         *Hub
+	transport   *HTTPTransport
+	guard       *AbuseGuard
+	origins     *OriginPolicy
 	gameManager *GameManager
-	db          *Database
+	db          Store
 	router      *gin.Engine
+	httpServer  *http.Server
 }
 
-func NewServer(port string, hub *Hub, gameManager *GameManager, db *Database) *Server {
+func NewServer(port string, hub *Hub, gameManager *GameManager, db Store, guard *AbuseGuard, origins *OriginPolicy) (*Server, error) {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
+	// Client IPs come from X-Forwarded-For only behind these proxies
+	if err := router.SetTrustedProxies(guard.cfg.TrustedProxies); err != nil {
+		return nil, err
+	}
 	
 	server := &Server{
 		port:        port,
 		hub:         hub,
+		transport:   NewHTTPTransport(hub, guard),
+		guard:       guard,
+		origins:     origins,
 		gameManager: gameManager,
 		db:          db,
 		router:      router,
+		httpServer:  &http.Server{Addr: ":" + port, Handler: router},
 	}
 
 	server.setupRoutes()
-	return server
+	return server, nil
 }
 
 func (s *Server) setupRoutes() {
-	// CORS middleware
-	s.router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	})
+	// CORS for the allowed origins only
+	s.router.Use(s.origins.Middleware())
+
+	// Per-IP request limits and temporary bans
+	s.router.Use(s.guard.Middleware())
 
 	// WebSocket endpoint
-	s.router.GET("/ws", func(c *gin.Context) {
-		HandleWebSocket(s.hub)(c.Writer, c.Request)
-	})
+	s.router.GET("/ws", HandleWebSocket(s.hub, s.guard, s.origins))
+
+	// Fallback transport for clients that can't use WebSockets
+	s.router.GET("/sse", s.transport.HandleSSE)
+	s.router.POST("/poll", s.transport.HandleOpenPoll)
+	s.router.GET("/poll/:sessionId", s.transport.HandlePoll)
+	s.router.POST("/session/:sessionId", s.transport.HandleAction)
 
 	// Root endpoint
 	s.router.GET("/", func(c *gin.Context) {
@@ -63,22 +72,15 @@ func (s *Server) setupRoutes() {
 	// API endpoints
 	s.router.GET("/api/leaderboard", s.getLeaderboard)
 	s.router.GET("/api/player/:username", s.getPlayerStats)
+	s.router.GET("/api/player/:username/games", s.getPlayerGames)
+	s.router.GET("/api/player/:username/profile", s.getPublicProfile)
+	s.router.PUT("/api/player/:username/profile", s.updateProfile)
+	s.router.GET("/api/player/:username/preferences", s.getPreferences)
 	s.router.GET("/api/game/:gameId", s.getGameState)
+	s.router.GET("/api/head-to-head/:a/:b", s.getHeadToHead)
 	s.router.GET("/health", s.health)
 }
 
-func (s *Server) getLeaderboard(c *gin.Context) {
-	leaderboard, err := s.db.GetLeaderboard(100)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"leaderboard": leaderboard,
-	})
-}
-
 func (s *Server) getPlayerStats(c *gin.Context) {
 	username := c.Param("username")
 	
@@ -88,6 +90,13 @@ func (s *Server) getPlayerStats(c *gin.Context) {
 		return
 	}
 
+	achievements, err := GetAchievements(s.db, username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch player stats"})
+		return
+	}
+	stats["achievements"] = achievements
+
 	c.JSON(http.StatusOK, stats)
 }
 
@@ -98,12 +107,32 @@ func (s *Server) getGameState(c *gin.Context) {
 	gameState := s.hub.games[gameID]
 	s.hub.mu.RUnlock()
 
-	if gameState == nil {
+	if gameState != nil {
+		c.JSON(http.StatusOK, gameState)
+		return
+	}
+
+	// Not live in this process; fall back to the saved game
+	game, err := s.db.GetGame(gameID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch game"})
+		return
+	}
+	if game == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Game not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gameState)
+	moves, err := s.db.GetMoves(gameID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch game"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"game":  game,
+		"moves": moves,
+	})
 }
 
 func (s *Server) health(c *gin.Context) {
@@ -114,5 +143,27 @@ func (s *Server) health(c *gin.Context) {
 
 func (s *Server) Start() error {
 	log.Printf("Starting server on port %s\n", s.port)
-	return s.router.Run(":" + s.port)
+	err := s.httpServer.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// StartDebugServer serves counters (rate limit violations, bans, event
+// delivery) in expvar format on addr, which should not be reachable from
+// outside.
+func StartDebugServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	log.Printf("Debug server listening on %s\n", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Println("Debug server error:", err)
+	}
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests.
+// Hijacked WebSocket connections are not waited for.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
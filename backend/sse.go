@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// How long a long-poll request waits for the first message.
+const pollWait = 25 * time.Second
+
+// Maximum number of messages returned by one long-poll request.
+const maxPollBatch = 64
+
+// HTTPTransport is the fallback for clients whose proxies break WebSockets.
+// Server events are delivered over a Server-Sent Events stream or collected by
+// long-poll requests; client actions are POSTed. Each stream or poll session is
+// a regular Client of the hub, so matchmaking and game logic are shared with
+// HandleWebSocket.
+type HTTPTransport struct {
+	hub      *Hub
+	guard    *AbuseGuard
+	mu       sync.RWMutex
+	sessions map[string]*httpSession
+}
+
+// httpSession is a Client reached over the HTTP transport. Actions are handled
+// one at a time, like messages read from a WebSocket, and so are polls, since
+// preparing messages for delivery is the writer's job.
+type httpSession struct {
+	client   *Client
+	ip       string
+	actionMu sync.Mutex
+	pollMu   sync.Mutex
+}
+
+func NewHTTPTransport(hub *Hub, guard *AbuseGuard) *HTTPTransport {
+	return &HTTPTransport{
+		hub:      hub,
+		guard:    guard,
+		sessions: make(map[string]*httpSession),
+	}
+}
+
+type SessionMessage struct {
+	SessionID string `json:"sessionId"`
+}
+
+// SSEConnection writes server events to an open text/event-stream response.
+type SSEConnection struct {
+	w         http.ResponseWriter
+	flusher   http.Flusher
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (sc *SSEConnection) WriteMessage(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if m, ok := msg.(*Message); ok && m.Seq > 0 {
+		fmt.Fprintf(sc.w, "id: %d\n", m.Seq)
+	}
+	if _, err := fmt.Fprintf(sc.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	sc.flusher.Flush()
+	return nil
+}
+
+func (sc *SSEConnection) WritePing() error {
+	if _, err := fmt.Fprint(sc.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	sc.flusher.Flush()
+	return nil
+}
+
+func (sc *SSEConnection) WriteClose() error {
+	return nil
+}
+
+func (sc *SSEConnection) Close() error {
+	sc.closeOnce.Do(func() { close(sc.done) })
+	return nil
+}
+
+// PollConnection holds a long-poll session open between requests. A session
+// that isn't polled within pongWait is considered dead.
+type PollConnection struct {
+	mu        sync.Mutex
+	lastPoll  time.Time
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (pc *PollConnection) touch() {
+	pc.mu.Lock()
+	pc.lastPoll = time.Now()
+	pc.mu.Unlock()
+}
+
+func (pc *PollConnection) idleFor() time.Duration {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return time.Since(pc.lastPoll)
+}
+
+func (pc *PollConnection) Close() error {
+	pc.closeOnce.Do(func() { close(pc.done) })
+	return nil
+}
+
+func (t *HTTPTransport) addSession(client *Client, ip string) string {
+	id := uuid.New().String()
+	t.mu.Lock()
+	t.sessions[id] = &httpSession{client: client, ip: ip}
+	t.mu.Unlock()
+	return id
+}
+
+func (t *HTTPTransport) removeSession(id string) {
+	t.mu.Lock()
+	delete(t.sessions, id)
+	t.mu.Unlock()
+}
+
+func (t *HTTPTransport) session(id string) *httpSession {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.sessions[id]
+}
+
+// HandleSSE opens an event stream. The first event carries the session ID the
+// client must use when POSTing actions.
+func (t *HTTPTransport) HandleSSE(c *gin.Context) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Streaming not supported"})
+		return
+	}
+
+	ip := c.ClientIP()
+	if !t.guard.OpenConnection(ip) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many connections"})
+		return
+	}
+	defer t.guard.CloseConnection(ip)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	conn := &SSEConnection{w: c.Writer, flusher: flusher, done: make(chan struct{})}
+	client := newClient(t.hub, conn)
+	client.deltaMoves = c.Query("moves") == "delta"
+
+	sessionID := t.addSession(client, ip)
+	t.hub.RegisterClient(client)
+
+	if err := conn.WriteMessage(&Message{Type: "session", Payload: SessionMessage{SessionID: sessionID}}); err != nil {
+		log.Println("SSE write error:", err)
+		conn.Close()
+	}
+
+	writerDone := make(chan struct{})
+	go func() {
+		client.writePump(conn)
+		close(writerDone)
+	}()
+
+	select {
+	case <-c.Request.Context().Done():
+	case <-conn.done:
+	}
+
+	t.removeSession(sessionID)
+	t.hub.UnregisterClient(client)
+	t.guard.ForgetClient(client)
+	// The response must not be written to once the handler returns
+	<-writerDone
+	log.Printf("SSE session %s closed\n", sessionID)
+}
+
+// HandleOpenPoll starts a long-poll session.
+func (t *HTTPTransport) HandleOpenPoll(c *gin.Context) {
+	ip := c.ClientIP()
+	if !t.guard.OpenConnection(ip) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many connections"})
+		return
+	}
+
+	conn := &PollConnection{lastPoll: time.Now(), done: make(chan struct{})}
+	client := newClient(t.hub, conn)
+	client.deltaMoves = c.Query("moves") == "delta"
+
+	sessionID := t.addSession(client, ip)
+	t.hub.RegisterClient(client)
+
+	go func() {
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if conn.idleFor() > pongWait {
+					conn.Close()
+				}
+			case <-conn.done:
+				t.removeSession(sessionID)
+				t.hub.UnregisterClient(client)
+				t.guard.ForgetClient(client)
+				t.guard.CloseConnection(ip)
+				log.Printf("Poll session %s closed\n", sessionID)
+				return
+			}
+		}
+	}()
+
+	c.JSON(http.StatusOK, SessionMessage{SessionID: sessionID})
+}
+
+// HandlePoll waits up to pollWait for server events on a long-poll session and
+// returns every event queued by then. A poll sent while another is waiting,
+// such as a retry after a dropped request, waits for it to finish.
+func (t *HTTPTransport) HandlePoll(c *gin.Context) {
+	session := t.session(c.Param("sessionId"))
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	client := session.client
+
+	conn, ok := client.conn.(*PollConnection)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not a long-poll session"})
+		return
+	}
+	conn.touch()
+	defer conn.touch()
+
+	session.pollMu.Lock()
+	defer session.pollMu.Unlock()
+
+	messages := make([]interface{}, 0)
+	timer := time.NewTimer(pollWait)
+	defer timer.Stop()
+
+	select {
+	case msg, ok := <-client.send:
+		if !ok {
+			c.JSON(http.StatusGone, gin.H{"error": "Session closed"})
+			return
+		}
+		messages = append(messages, client.prepare(msg))
+	case <-timer.C:
+	case <-c.Request.Context().Done():
+		return
+	}
+
+drain:
+	for len(messages) < maxPollBatch {
+		select {
+		case msg, ok := <-client.send:
+			if !ok {
+				break drain
+			}
+			messages = append(messages, client.prepare(msg))
+		default:
+			break drain
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// HandleAction accepts a client message for an SSE or long-poll session.
+// Acks, errors and resulting events are delivered on the session.
+func (t *HTTPTransport) HandleAction(c *gin.Context) {
+	session := t.session(c.Param("sessionId"))
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, t.guard.cfg.MaxFrameBytes)
+
+	var msg InboundMessage
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message"})
+		return
+	}
+	if msg.Type == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing message type"})
+		return
+	}
+
+	if !t.guard.AllowMessage(session.client, session.ip) {
+		session.client.sendError(msg.RequestID, "Rate limit exceeded")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+		return
+	}
+
+	session.actionMu.Lock()
+	t.hub.HandleMessage(session.client, &msg)
+	session.actionMu.Unlock()
+	c.JSON(http.StatusAccepted, gin.H{"status": "accepted"})
+}
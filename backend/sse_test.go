@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestTransport(t *testing.T) (*HTTPTransport, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	hub := NewHub(NewGameManager(NewMemoryStore(), NoopSink{}))
+	go hub.Run()
+
+	transport := NewHTTPTransport(hub, NewAbuseGuard(LoadAbuseConfig()))
+	router := gin.New()
+	router.POST("/poll", transport.HandleOpenPoll)
+	router.GET("/poll/:sessionId", transport.HandlePoll)
+	return transport, router
+}
+
+func openPoll(t *testing.T, router *gin.Engine, url string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", url, nil))
+	var opened SessionMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &opened); err != nil || opened.SessionID == "" {
+		t.Fatalf("opening a poll session: %d %s", w.Code, w.Body)
+	}
+	return opened.SessionID
+}
+
+type pollResponse struct {
+	Messages []struct {
+		Type    string                 `json:"type"`
+		Payload map[string]interface{} `json:"payload"`
+	} `json:"messages"`
+}
+
+// poll returns the messages from one poll, and false once the session is
+// closed.
+func poll(t *testing.T, router *gin.Engine, sessionID string) (pollResponse, bool) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/poll/"+sessionID, nil))
+	var resp pollResponse
+	switch {
+	case w.Code == http.StatusGone || w.Code == http.StatusNotFound:
+		return resp, false
+	case w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil:
+		t.Errorf("poll: %d %s", w.Code, w.Body)
+		return resp, false
+	}
+	return resp, true
+}
+
+func TestPollDeltaMoves(t *testing.T) {
+	transport, router := newTestTransport(t)
+
+	for _, tt := range []struct {
+		url       string
+		wantBoard bool
+	}{
+		{"/poll", true},
+		{"/poll?moves=delta", false},
+	} {
+		sessionID := openPoll(t, router, tt.url)
+		client := transport.session(sessionID).client
+		client.send <- &Message{Type: "game_start", Seq: 1, Payload: GameStartMessage{GameID: "g1"}}
+		client.send <- &Message{Type: "game_move", Seq: 2, Payload: GameMoveEventMessage{GameID: "g1", Board: testBoard()}}
+
+		resp, _ := poll(t, router, sessionID)
+		if len(resp.Messages) != 2 || resp.Messages[1].Type != "game_move" {
+			t.Fatalf("%s: messages = %+v, want game_start and game_move", tt.url, resp.Messages)
+		}
+		if _, ok := resp.Messages[1].Payload["board"]; ok != tt.wantBoard {
+			t.Fatalf("%s: game_move has board = %v, want %v", tt.url, ok, tt.wantBoard)
+		}
+	}
+}
+
+// TestConcurrentPolls keeps several polls going on one session, as a client
+// retrying a poll it thinks was dropped would, while events arrive. Each
+// event is delivered once; run with -race to check the polls don't prepare
+// messages concurrently.
+func TestConcurrentPolls(t *testing.T) {
+	transport, router := newTestTransport(t)
+	sessionID := openPoll(t, router, "/poll?moves=delta")
+	client := transport.session(sessionID).client
+
+	var mu sync.Mutex
+	received := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				resp, open := poll(t, router, sessionID)
+				if !open {
+					return
+				}
+				for _, m := range resp.Messages {
+					if _, ok := m.Payload["board"]; ok && m.Type == "game_move" {
+						t.Errorf("game_move for %v has a board", m.Payload["gameId"])
+					}
+				}
+				mu.Lock()
+				received += len(resp.Messages)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	const games = 200
+	for i := 0; i < games; i++ {
+		id := fmt.Sprint("g", i)
+		client.send <- &Message{Type: "game_start", Payload: GameStartMessage{GameID: id}}
+		client.send <- &Message{Type: "game_move", Payload: GameMoveEventMessage{GameID: id, Board: testBoard()}}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := received
+		mu.Unlock()
+		if n == 2*games || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	client.conn.Close()
+	wg.Wait()
+	if received != 2*games {
+		t.Fatalf("received %d messages, want %d", received, 2*games)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Store persists games, their moves, player stats and leaderboards. Database
+// is the Postgres implementation; SQLiteStore and MemoryStore let the backend
+// run without any external services.
+type Store interface {
+	// RecordResult saves a game with its moves and stats, and adds events to
+	// the outbox, all atomically. It is idempotent: nothing changes after the
+	// game's first transition to finished, and the returned bool reports
+	// whether this call was that transition.
+	RecordResult(game *GameState, events ...GameEvent) (bool, error)
+	// PendingEvents returns up to limit undelivered outbox events, oldest
+	// first.
+	PendingEvents(limit int) ([]OutboxEvent, error)
+	MarkDelivered(ids []string) error
+	DeleteDeliveredEvents(before time.Time) (int64, error)
+	GetGame(gameID string) (*GameRecord, error)
+	GetMoves(gameID string) ([]Move, error)
+	// ListPlayerGames returns a page of a player's finished games matching q.
+	ListPlayerGames(q GameQuery) ([]PlayerGame, error)
+	GetPlayerStats(username string) (map[string]interface{}, error)
+	GetLeaderboard(q LeaderboardQuery) (*Leaderboard, error)
+	// UnlockAchievements records achievements for a player, ignoring ones
+	// they already have, and returns those that were new.
+	UnlockAchievements(username string, ids []string, at time.Time) ([]UnlockedAchievement, error)
+	GetAchievements(username string) ([]UnlockedAchievement, error)
+	// GetProfile returns a player's profile, or the default one if they
+	// never saved one.
+	GetProfile(username string) (*Profile, error)
+	SaveProfile(profile *Profile) error
+	// ClaimPlayerToken stores tokenHash as username's token unless they
+	// already have one, and reports whether it was stored.
+	ClaimPlayerToken(username string, tokenHash string) (bool, error)
+	// PlayerTokenHash returns the hash of username's token, or "" if they
+	// have none.
+	PlayerTokenHash(username string) (string, error)
+	// ListGamesBefore returns up to limit finished games started before
+	// before, oldest first, with their moves.
+	ListGamesBefore(before time.Time, limit int) ([]ArchivedGame, error)
+	// DeleteArchivedGames deletes games, oldest first, once they are
+	// archived, folding them into the archived totals in the same
+	// transaction so all-time standings and head-to-head records keep
+	// counting them. Games already deleted are skipped.
+	DeleteArchivedGames(games []ArchivedGame) error
+	// ArchivedHeadToHead returns the archived totals between a and b from
+	// a's side.
+	ArchivedHeadToHead(a string, b string) (HeadToHeadTotals, error)
+	// AnonymizePlayer replaces username with alias in every game, outbox
+	// event and archived head-to-head record and deletes the player's own
+	// rows. It reports whether the player existed.
+	AnonymizePlayer(username string, alias string) (bool, error)
+	Close() error
+}
+
+// Move is one disc drop, numbered from 1 within its game.
+type Move struct {
+	Number   int       `json:"number"`
+	Player   int       `json:"player"`
+	Column   int       `json:"column"`
+	Row      int       `json:"row"`
+	PlayedAt time.Time `json:"playedAt"`
+}
+
+// GameRecord is a saved game.
+type GameRecord struct {
+	ID              string    `json:"id"`
+	Player1         string    `json:"player1"`
+	Player2         string    `json:"player2"`
+	Winner          string    `json:"winner"`
+	IsBot           bool      `json:"isBot"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+	DurationSeconds int       `json:"durationSeconds"`
+}
+
+// StoreConfig selects the Store implementation.
+type StoreConfig struct {
+	Kind        string // "postgres" (default), "sqlite" or "memory"
+	DatabaseURL string
+	SQLitePath  string
+}
+
+func LoadStoreConfig() StoreConfig {
+	cfg := StoreConfig{
+		Kind:        os.Getenv("STORE"),
+		DatabaseURL: os.Getenv("DATABASE_URL"),
+		SQLitePath:  os.Getenv("SQLITE_PATH"),
+	}
+	if cfg.Kind == "" {
+		cfg.Kind = "postgres"
+	}
+	if cfg.SQLitePath == "" {
+		cfg.SQLitePath = "4_in_a_row.db"
+	}
+	return cfg
+}
+
+// OpenStore opens the configured store and brings its schema up to date.
+func OpenStore(cfg StoreConfig) (Store, error) {
+	switch cfg.Kind {
+	case "postgres":
+		if cfg.DatabaseURL == "" {
+			return nil, fmt.Errorf("DATABASE_URL not set")
+		}
+		return InitDB(cfg.DatabaseURL)
+	case "sqlite":
+		return OpenSQLiteStore(cfg.SQLitePath)
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown STORE %q (want postgres, sqlite or memory)", cfg.Kind)
+	}
+}
+
+// gameTimes parses a game's timestamps and its duration in seconds.
+func gameTimes(game *GameState) (time.Time, time.Time, int) {
+	createdAt, _ := time.Parse(time.RFC3339, game.CreatedAt)
+	updatedAt, _ := time.Parse(time.RFC3339, game.UpdatedAt)
+	return createdAt, updatedAt, int(updatedAt.Sub(createdAt).Seconds())
+}
+
+// resultDeltas returns whose wins, losses and draws a finished game adds to.
+// The bot has no stats of its own.
+func resultDeltas(game *GameState) (wins []string, losses []string, draws []string) {
+	switch {
+	case game.Winner == "":
+		return nil, nil, nil
+	case game.Winner == "draw":
+		draws = append(draws, game.Player1)
+		if !game.IsBot {
+			draws = append(draws, game.Player2)
+		}
+	case game.Winner == "Bot":
+		losses = append(losses, game.Player1)
+	default:
+		wins = append(wins, game.Winner)
+		if !game.IsBot {
+			if game.Player1 == game.Winner {
+				losses = append(losses, game.Player2)
+			} else {
+				losses = append(losses, game.Player1)
+			}
+		}
+	}
+	return wins, losses, draws
+}
+
+// formatWinRate renders wins/total as the percentage string the API returns.
+func formatWinRate(wins int, total int) string {
+	if total == 0 {
+		return "0.00%"
+	}
+	return fmt.Sprintf("%.2f%%", float64(wins)/float64(total)*100)
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps everything in process memory. Data is lost on restart;
+// it exists for development and tests.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	games   map[string]*GameRecord
+	moves   map[string][]Move
+	players map[string]*memoryPlayer
+
+	achievements map[string][]UnlockedAchievement
+	outbox       []*memoryOutboxEvent
+	tokens       map[string]string // token hashes by username
+
+	archivedResults    map[string]*memoryArchivedResults
+	archivedHeadToHead map[[2]string]*HeadToHeadTotals // keyed by players A and B
+}
+
+// memoryArchivedResults are a player's results from archived games, with bot
+// games also counted apart.
+type memoryArchivedResults struct {
+	games, wins, draws          int
+	botGames, botWins, botDraws int
+}
+
+type memoryOutboxEvent struct {
+	OutboxEvent
+	deliveredAt time.Time
+}
+
+type memoryPlayer struct {
+	wins, losses, draws int
+	rating              int
+	profile             *Profile
+	createdAt           time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		games:   make(map[string]*GameRecord),
+		moves:   make(map[string][]Move),
+		players: make(map[string]*memoryPlayer),
+
+		achievements: make(map[string][]UnlockedAchievement),
+		tokens:       make(map[string]string),
+
+		archivedResults:    make(map[string]*memoryArchivedResults),
+		archivedHeadToHead: make(map[[2]string]*HeadToHeadTotals),
+	}
+}
+
+func (m *MemoryStore) RecordResult(game *GameState, events ...GameEvent) (bool, error) {
+	createdAt, updatedAt, duration := gameTimes(game)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.games[game.ID]; ok && existing.Status == "finished" {
+		return false, nil
+	}
+
+	m.games[game.ID] = &GameRecord{
+		ID:              game.ID,
+		Player1:         game.Player1,
+		Player2:         game.Player2,
+		Winner:          game.Winner,
+		IsBot:           game.IsBot,
+		Status:          game.Status,
+		CreatedAt:       createdAt,
+		UpdatedAt:       updatedAt,
+		DurationSeconds: duration,
+	}
+	m.moves[game.ID] = append([]Move(nil), game.Moves...)
+
+	if game.Status == "finished" {
+		wins, losses, draws := resultDeltas(game)
+		for _, username := range wins {
+			m.player(username).wins++
+		}
+		for _, username := range losses {
+			m.player(username).losses++
+		}
+		for _, username := range draws {
+			m.player(username).draws++
+		}
+
+		if score1, ok := ratingScore(game); ok {
+			p1 := m.player(game.Player1)
+			if game.IsBot {
+				p1.rating, _ = eloRatings(p1.rating, botRating, score1)
+			} else {
+				p2 := m.player(game.Player2)
+				p1.rating, p2.rating = eloRatings(p1.rating, p2.rating, score1)
+			}
+		}
+	}
+
+	for _, event := range events {
+		if !m.inOutbox(event.EventID) {
+			m.outbox = append(m.outbox, &memoryOutboxEvent{OutboxEvent: OutboxEvent{ID: event.EventID, Event: event}})
+		}
+	}
+	return true, nil
+}
+
+// inOutbox reports whether an event with this ID was already added, so it
+// isn't added twice. Callers must hold m.mu.
+func (m *MemoryStore) inOutbox(id string) bool {
+	for _, e := range m.outbox {
+		if e.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// player returns username's stats row, creating it. Callers must hold m.mu.
+func (m *MemoryStore) player(username string) *memoryPlayer {
+	p, ok := m.players[username]
+	if !ok {
+		p = &memoryPlayer{rating: initialRating, createdAt: time.Now()}
+		m.players[username] = p
+	}
+	return p
+}
+
+func (m *MemoryStore) GetGame(gameID string) (*GameRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	game, ok := m.games[gameID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *game
+	return &copied, nil
+}
+
+func (m *MemoryStore) GetMoves(gameID string) ([]Move, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append(make([]Move, 0), m.moves[gameID]...), nil
+}
+
+func (m *MemoryStore) ListPlayerGames(q GameQuery) ([]PlayerGame, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches := make([]*GameRecord, 0)
+	for _, game := range m.games {
+		if game.Status != "finished" || (game.Player1 != q.Username && game.Player2 != q.Username) {
+			continue
+		}
+		if q.Opponent != "" && game.Player1 != q.Opponent && game.Player2 != q.Opponent {
+			continue
+		}
+		if q.Result != "" && gameResult(game.Winner, q.Username) != q.Result {
+			continue
+		}
+		if q.IsBot != nil && game.IsBot != *q.IsBot {
+			continue
+		}
+		if !q.From.IsZero() && game.CreatedAt.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && !game.CreatedAt.Before(q.To) {
+			continue
+		}
+		if q.After != nil && !gameBefore(game, q.After) {
+			continue
+		}
+		matches = append(matches, game)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return gameBefore(matches[j], &GameCursor{CreatedAt: matches[i].CreatedAt, ID: matches[i].ID})
+	})
+	if len(matches) > q.Limit {
+		matches = matches[:q.Limit]
+	}
+
+	games := make([]PlayerGame, 0, len(matches))
+	for _, game := range matches {
+		games = append(games, newPlayerGame(game, q.Username, len(m.moves[game.ID])))
+	}
+	return games, nil
+}
+
+// gameBefore reports whether game sorts after cursor in newest-first order.
+func gameBefore(game *GameRecord, cursor *GameCursor) bool {
+	if !game.CreatedAt.Equal(cursor.CreatedAt) {
+		return game.CreatedAt.Before(cursor.CreatedAt)
+	}
+	return game.ID < cursor.ID
+}
+
+func (m *MemoryStore) GetPlayerStats(username string) (map[string]interface{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.players[username]
+	if !ok {
+		return map[string]interface{}{
+			"username": username,
+			"wins":     0,
+			"losses":   0,
+			"draws":    0,
+			"winRate":  "0.00%",
+			"rating":   initialRating,
+		}, nil
+	}
+
+	return map[string]interface{}{
+		"username":  username,
+		"wins":      p.wins,
+		"losses":    p.losses,
+		"draws":     p.draws,
+		"winRate":   formatWinRate(p.wins, p.wins+p.losses+p.draws),
+		"rating":    p.rating,
+		"createdAt": p.createdAt,
+	}, nil
+}
+
+func (m *MemoryStore) GetLeaderboard(q LeaderboardQuery) (*Leaderboard, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	totals := make(map[string]*LeaderboardEntry)
+	add := func(username string, games, wins, draws int) {
+		p, ok := m.players[username]
+		if !ok || games == 0 {
+			return // deleted account, or only archived bot games
+		}
+		entry, ok := totals[username]
+		if !ok {
+			entry = &LeaderboardEntry{Username: username, Rating: p.rating}
+			totals[username] = entry
+		}
+		entry.Games += games
+		entry.Wins += wins
+		entry.Draws += draws
+		entry.Losses += games - wins - draws
+	}
+	addGame := func(username string, winner string) {
+		switch gameResult(winner, username) {
+		case "win":
+			add(username, 1, 1, 0)
+		case "draw":
+			add(username, 1, 0, 1)
+		default:
+			add(username, 1, 0, 0)
+		}
+	}
+
+	// Archived games only have all-time totals, so windows leave them out
+	if q.Since.IsZero() {
+		for username, r := range m.archivedResults {
+			if q.ExcludeBots {
+				add(username, r.games-r.botGames, r.wins-r.botWins, r.draws-r.botDraws)
+			} else {
+				add(username, r.games, r.wins, r.draws)
+			}
+		}
+	}
+
+	for _, game := range m.games {
+		if game.Status != "finished" || game.CreatedAt.Before(q.Since) {
+			continue
+		}
+		if q.ExcludeBots && game.IsBot {
+			continue
+		}
+		addGame(game.Player1, game.Winner)
+		if !game.IsBot {
+			addGame(game.Player2, game.Winner)
+		}
+	}
+
+	entries := make([]LeaderboardEntry, 0, len(totals))
+	for _, entry := range totals {
+		if entry.Games < q.MinGames {
+			continue
+		}
+		entry.WinRate = percent(float64(entry.Wins) / float64(entry.Games))
+		entries = append(entries, *entry)
+	}
+	return rankLeaderboard(entries, q), nil
+}
+
+func (m *MemoryStore) UnlockAchievements(username string, ids []string, at time.Time) ([]UnlockedAchievement, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	have := make(map[string]bool)
+	for _, u := range m.achievements[username] {
+		have[u.ID] = true
+	}
+
+	added := make([]UnlockedAchievement, 0)
+	for _, id := range ids {
+		if have[id] {
+			continue
+		}
+		have[id] = true
+		added = append(added, UnlockedAchievement{ID: id, UnlockedAt: at})
+	}
+	m.achievements[username] = append(m.achievements[username], added...)
+	return added, nil
+}
+
+func (m *MemoryStore) GetAchievements(username string) ([]UnlockedAchievement, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append(make([]UnlockedAchievement, 0), m.achievements[username]...), nil
+}
+
+func (m *MemoryStore) GetProfile(username string) (*Profile, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.players[username]
+	if !ok || p.profile == nil {
+		return defaultProfile(username), nil
+	}
+	copied := *p.profile
+	return &copied, nil
+}
+
+func (m *MemoryStore) SaveProfile(profile *Profile) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	copied := *profile
+	m.player(profile.Username).profile = &copied
+	return nil
+}
+
+func (m *MemoryStore) ClaimPlayerToken(username string, tokenHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tokens[username]; ok {
+		return false, nil
+	}
+	m.tokens[username] = tokenHash
+	return true, nil
+}
+
+func (m *MemoryStore) PlayerTokenHash(username string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.tokens[username], nil
+}
+
+func (m *MemoryStore) ListGamesBefore(before time.Time, limit int) ([]ArchivedGame, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches := make([]*GameRecord, 0)
+	for _, game := range m.games {
+		if game.Status == "finished" && game.CreatedAt.Before(before) {
+			matches = append(matches, game)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].CreatedAt.Before(matches[j].CreatedAt)
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	games := make([]ArchivedGame, 0, len(matches))
+	for _, game := range matches {
+		games = append(games, ArchivedGame{
+			Game:  *game,
+			Moves: append(make([]Move, 0), m.moves[game.ID]...),
+		})
+	}
+	return games, nil
+}
+
+func (m *MemoryStore) DeleteArchivedGames(games []ArchivedGame) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, g := range games {
+		game := &g.Game
+		if _, ok := m.games[game.ID]; !ok {
+			continue // archived before
+		}
+		delete(m.games, game.ID)
+		delete(m.moves, game.ID)
+
+		m.addArchivedResult(game, game.Player1)
+		if !game.IsBot {
+			m.addArchivedResult(game, game.Player2)
+		}
+
+		a, b := game.Player1, game.Player2
+		t, ok := m.archivedHeadToHead[[2]string{a, b}]
+		if !ok {
+			if t, ok = m.archivedHeadToHead[[2]string{b, a}]; ok {
+				a = b
+			} else {
+				t = &HeadToHeadTotals{}
+				m.archivedHeadToHead[[2]string{a, b}] = t
+			}
+		}
+		t.add(newPlayerGame(game, a, len(g.Moves)))
+	}
+	return nil
+}
+
+func (m *MemoryStore) addArchivedResult(game *GameRecord, username string) {
+	r, ok := m.archivedResults[username]
+	if !ok {
+		r = &memoryArchivedResults{}
+		m.archivedResults[username] = r
+	}
+	result := gameResult(game.Winner, username)
+	r.games++
+	if game.IsBot {
+		r.botGames++
+	}
+	switch {
+	case result == "win":
+		r.wins++
+		if game.IsBot {
+			r.botWins++
+		}
+	case result == "draw":
+		r.draws++
+		if game.IsBot {
+			r.botDraws++
+		}
+	}
+}
+
+func (m *MemoryStore) ArchivedHeadToHead(a string, b string) (HeadToHeadTotals, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if t, ok := m.archivedHeadToHead[[2]string{a, b}]; ok {
+		return *t, nil
+	}
+	if t, ok := m.archivedHeadToHead[[2]string{b, a}]; ok {
+		return t.swap(), nil
+	}
+	return HeadToHeadTotals{}, nil
+}
+
+func (m *MemoryStore) AnonymizePlayer(username string, alias string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, found := m.players[username]
+	for _, game := range m.games {
+		for _, field := range []*string{&game.Player1, &game.Player2, &game.Winner} {
+			if *field == username {
+				*field = alias
+				found = true
+			}
+		}
+	}
+
+	for _, e := range m.outbox {
+		if anonymizeEvent(&e.Event, username, alias) {
+			found = true
+		}
+	}
+	for key, t := range m.archivedHeadToHead {
+		renamed := key
+		for i := range renamed {
+			if renamed[i] == username {
+				renamed[i] = alias
+			}
+		}
+		if renamed != key {
+			delete(m.archivedHeadToHead, key)
+			m.archivedHeadToHead[renamed] = t
+			found = true
+		}
+	}
+
+	delete(m.players, username)
+	delete(m.achievements, username)
+	delete(m.tokens, username)
+	delete(m.archivedResults, username)
+	return found, nil
+}
+
+func (m *MemoryStore) PendingEvents(limit int) ([]OutboxEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	pending := make([]OutboxEvent, 0)
+	for _, e := range m.outbox {
+		if len(pending) == limit {
+			break
+		}
+		if e.deliveredAt.IsZero() {
+			pending = append(pending, e.OutboxEvent)
+		}
+	}
+	return pending, nil
+}
+
+func (m *MemoryStore) MarkDelivered(ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delivered := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		delivered[id] = true
+	}
+	now := time.Now()
+	for _, e := range m.outbox {
+		if delivered[e.ID] && e.deliveredAt.IsZero() {
+			e.deliveredAt = now
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteDeliveredEvents(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.outbox[:0]
+	var deleted int64
+	for _, e := range m.outbox {
+		if !e.deliveredAt.IsZero() && e.deliveredAt.Before(before) {
+			deleted++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	m.outbox = kept
+	return deleted, nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore keeps everything in a local SQLite file, for development and
+// tests without Postgres. The queries are shared with Database; only the
+// schema differs.
+type SQLiteStore struct {
+	*Database
+}
+
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	conn, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := NewSQLiteMigrator(conn).Up(0); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	log.Printf("SQLite store initialized at %s\n", path)
+	return &SQLiteStore{Database: &Database{conn: conn}}, nil
+}
+
+func openSQLite(path string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer; one connection avoids "database is locked"
+	conn.SetMaxOpenConns(1)
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"eventschema"
+)
+
+// testStores opens a fresh instance of every Store that runs without external
+// services. Each test runs against all of them, so they must agree.
+var testStores = []struct {
+	name string
+	open func(t *testing.T) Store
+}{
+	{"memory", func(t *testing.T) Store { return NewMemoryStore() }},
+	{"sqlite", func(t *testing.T) Store {
+		db, err := OpenSQLiteStore(t.TempDir() + "/test.db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}},
+}
+
+func forEachStore(t *testing.T, test func(t *testing.T, db Store)) {
+	for _, s := range testStores {
+		t.Run(s.name, func(t *testing.T) {
+			db := s.open(t)
+			t.Cleanup(func() { db.Close() })
+			test(t, db)
+		})
+	}
+}
+
+var testStart = time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)
+
+// finishedGame is a game between player1 and player2 that started at
+// createdAt, lasted a minute and took moves moves.
+func finishedGame(id string, player1 string, player2 string, winner string, createdAt time.Time, moves int) *GameState {
+	game := &GameState{
+		ID:        id,
+		Board:     NewBoard(),
+		Player1:   player1,
+		Player2:   player2,
+		Status:    "finished",
+		Winner:    winner,
+		CreatedAt: createdAt.Format(time.RFC3339),
+		UpdatedAt: createdAt.Add(time.Minute).Format(time.RFC3339),
+		IsBot:     player2 == "Bot",
+	}
+	for i := 1; i <= moves; i++ {
+		game.Moves = append(game.Moves, Move{
+			Number:   i,
+			Player:   2 - i%2,
+			Column:   i % COLS,
+			Row:      ROWS - 1 - i/COLS,
+			PlayedAt: createdAt.Add(time.Duration(i) * time.Second),
+		})
+	}
+	return game
+}
+
+func recordGames(t *testing.T, db Store, games ...*GameState) {
+	t.Helper()
+	for _, game := range games {
+		if _, err := db.RecordResult(game); err != nil {
+			t.Fatalf("recording %s: %v", game.ID, err)
+		}
+	}
+}
+
+func TestStoreRecordResultIdempotent(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		game := finishedGame("g1", "alice", "bob", "alice", testStart, 7)
+		completed := newGameEvent(eventschema.GameCompleted, game, 8)
+
+		active := *game
+		active.Status, active.Winner = "active", ""
+		for i, step := range []struct {
+			game *GameState
+			want bool
+		}{
+			{&active, true},
+			{game, true},
+			{game, false},
+			{&active, false},
+		} {
+			applied, err := db.RecordResult(step.game, completed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if applied != step.want {
+				t.Fatalf("call %d: applied = %v, want %v", i, applied, step.want)
+			}
+		}
+
+		for _, want := range []struct {
+			username             string
+			wins, losses, rating int
+		}{
+			{"alice", 1, 0, 1216},
+			{"bob", 0, 1, 1184},
+		} {
+			stats, err := db.GetPlayerStats(want.username)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := fmt.Sprint(stats["wins"], stats["losses"], stats["rating"])
+			if exp := fmt.Sprint(want.wins, want.losses, want.rating); got != exp {
+				t.Fatalf("%s: wins, losses and rating = %s, want %s", want.username, got, exp)
+			}
+		}
+
+		record, err := db.GetGame("g1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record == nil || record.Status != "finished" || record.Winner != "alice" {
+			t.Fatalf("game = %+v, want finished and won by alice", record)
+		}
+		moves, err := db.GetMoves("g1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(moves) != 7 {
+			t.Fatalf("%d moves, want 7", len(moves))
+		}
+
+		// The event went into the outbox once
+		pending, err := db.PendingEvents(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) != 1 || pending[0].ID != completed.EventID {
+			t.Fatalf("pending events = %+v, want only %s", pending, completed.EventID)
+		}
+	})
+}
+
+func TestStoreListPlayerGames(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		active := finishedGame("g8", "alice", "bob", "", testStart.Add(4*time.Hour), 2)
+		active.Status = "active"
+		recordGames(t, db,
+			finishedGame("g1", "alice", "bob", "alice", testStart, 7),
+			finishedGame("g2", "bob", "alice", "bob", testStart.Add(time.Hour), 9),
+			finishedGame("g3", "carol", "alice", "draw", testStart.Add(2*time.Hour), 42),
+			finishedGame("g4", "alice", "Bot", "alice", testStart.Add(3*time.Hour), 11),
+			finishedGame("g5", "alice", "carol", "alice", testStart.Add(3*time.Hour), 13),
+			finishedGame("g6", "bob", "carol", "carol", testStart.Add(3*time.Hour), 8),
+			finishedGame("g7", "alice", "Bot", "Bot", testStart.Add(3*time.Hour+time.Second), 10),
+			active,
+		)
+
+		yes, no := true, false
+		tests := []struct {
+			name string
+			q    GameQuery
+			want []string
+		}{
+			{"all", GameQuery{}, []string{"g7", "g5", "g4", "g3", "g2", "g1"}},
+			{"opponent", GameQuery{Opponent: "bob"}, []string{"g2", "g1"}},
+			{"wins", GameQuery{Result: "win"}, []string{"g5", "g4", "g1"}},
+			{"losses", GameQuery{Result: "loss"}, []string{"g7", "g2"}},
+			{"draws", GameQuery{Result: "draw"}, []string{"g3"}},
+			{"bot games", GameQuery{IsBot: &yes}, []string{"g7", "g4"}},
+			{"human games", GameQuery{IsBot: &no}, []string{"g5", "g3", "g2", "g1"}},
+			{"from is inclusive", GameQuery{From: testStart.Add(time.Hour)}, []string{"g7", "g5", "g4", "g3", "g2"}},
+			{"to is exclusive", GameQuery{To: testStart.Add(3 * time.Hour)}, []string{"g3", "g2", "g1"}},
+			{"range and result", GameQuery{From: testStart, To: testStart.Add(4 * time.Hour), Result: "win", IsBot: &no}, []string{"g5", "g1"}},
+			{"after a cursor", GameQuery{After: &GameCursor{CreatedAt: testStart.Add(3 * time.Hour), ID: "g5"}}, []string{"g4", "g3", "g2", "g1"}},
+			{"nobody", GameQuery{Username: "dave"}, []string{}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if tt.q.Username == "" {
+					tt.q.Username = "alice"
+				}
+				tt.q.Limit = 100
+				games, err := db.ListPlayerGames(tt.q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := gameIDs(games); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("games = %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		t.Run("seen from the player's side", func(t *testing.T) {
+			games, err := db.ListPlayerGames(GameQuery{Username: "alice", Opponent: "carol", Limit: 100})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []PlayerGame{
+				{ID: "g5", Opponent: "carol", Result: "win", MovedFirst: true, DurationSeconds: 60, MoveCount: 13, PlayedAt: testStart.Add(3 * time.Hour), ReplayURL: "/api/game/g5"},
+				{ID: "g3", Opponent: "carol", Result: "draw", MovedFirst: false, DurationSeconds: 60, MoveCount: 42, PlayedAt: testStart.Add(2 * time.Hour), ReplayURL: "/api/game/g3"},
+			}
+			for i := range games {
+				games[i].PlayedAt = games[i].PlayedAt.UTC()
+			}
+			if !reflect.DeepEqual(games, want) {
+				t.Fatalf("games = %+v, want %+v", games, want)
+			}
+		})
+
+		// Pages follow each other without gaps or repeats, even through
+		// games that started at the same time
+		t.Run("cursor paging", func(t *testing.T) {
+			q := GameQuery{Username: "alice", Limit: 2}
+			var pages [][]string
+			for len(pages) < 10 {
+				games, err := db.ListPlayerGames(q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(games) == 0 {
+					break
+				}
+				pages = append(pages, gameIDs(games))
+				last := games[len(games)-1]
+				if q.After, err = DecodeGameCursor(GameCursor{CreatedAt: last.PlayedAt, ID: last.ID}.Encode()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := [][]string{{"g7", "g5"}, {"g4", "g3"}, {"g2", "g1"}}
+			if !reflect.DeepEqual(pages, want) {
+				t.Fatalf("pages = %v, want %v", pages, want)
+			}
+		})
+	})
+}
+
+func gameIDs(games []PlayerGame) []string {
+	ids := make([]string, 0, len(games))
+	for _, game := range games {
+		ids = append(ids, game.ID)
+	}
+	return ids
+}
+
+// leaderboardGames are played before and after the start of a window at
+// testStart plus a day:
+//
+//	all time:  alice 3 games, 2 wins, 1 draw; bob 4 games, 2 wins (1 against
+//	           the bot), 2 losses; carol 3 games, 1 draw, 2 losses
+//	in window: bob 2 wins (1 against the bot); carol 1 draw, 1 loss;
+//	           alice 1 draw
+func leaderboardGames() []*GameState {
+	window := testStart.Add(24 * time.Hour)
+	return []*GameState{
+		finishedGame("old1", "alice", "bob", "alice", testStart, 7),
+		finishedGame("old2", "carol", "alice", "alice", testStart.Add(time.Hour), 9),
+		finishedGame("old3", "bob", "Bot", "Bot", testStart.Add(2*time.Hour), 8),
+		finishedGame("new1", "bob", "carol", "bob", window.Add(time.Hour), 11),
+		finishedGame("new2", "bob", "Bot", "bob", window.Add(2*time.Hour), 13),
+		finishedGame("new3", "carol", "alice", "draw", window.Add(3*time.Hour), 42),
+	}
+}
+
+// standings renders a leaderboard as "rank username games/wins/losses/draws"
+// lines.
+func standings(entries []LeaderboardEntry) []string {
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, fmt.Sprintf("%d %s %d/%d/%d/%d", e.Rank, e.Username, e.Games, e.Wins, e.Losses, e.Draws))
+	}
+	return lines
+}
+
+func TestStoreGetLeaderboard(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		recordGames(t, db, leaderboardGames()...)
+
+		window := testStart.Add(24 * time.Hour)
+		tests := []struct {
+			name       string
+			q          LeaderboardQuery
+			want       []string
+			wantTotal  int
+			wantPlayer string
+		}{
+			{
+				name:      "all time by wins",
+				q:         LeaderboardQuery{SortBy: "wins"},
+				want:      []string{"1 alice 3/2/0/1", "2 bob 4/2/2/0", "3 carol 3/0/2/1"},
+				wantTotal: 3,
+			},
+			{
+				name:      "all time by win rate",
+				q:         LeaderboardQuery{SortBy: "winRate"},
+				want:      []string{"1 alice 3/2/0/1", "2 bob 4/2/2/0", "3 carol 3/0/2/1"},
+				wantTotal: 3,
+			},
+			{
+				name:      "all time without bot games",
+				q:         LeaderboardQuery{SortBy: "wins", ExcludeBots: true},
+				want:      []string{"1 alice 3/2/0/1", "2 bob 2/1/1/0", "3 carol 3/0/2/1"},
+				wantTotal: 3,
+			},
+			{
+				name:      "window",
+				q:         LeaderboardQuery{SortBy: "wins", Since: window},
+				want:      []string{"1 bob 2/2/0/0", "2 alice 1/0/0/1", "3 carol 2/0/1/1"},
+				wantTotal: 3,
+			},
+			{
+				name:      "window without bot games",
+				q:         LeaderboardQuery{SortBy: "wins", Since: window, ExcludeBots: true},
+				want:      []string{"1 bob 1/1/0/0", "2 alice 1/0/0/1", "3 carol 2/0/1/1"},
+				wantTotal: 3,
+			},
+			{
+				name:      "minimum games",
+				q:         LeaderboardQuery{SortBy: "wins", Since: window, MinGames: 2},
+				want:      []string{"1 bob 2/2/0/0", "2 carol 2/0/1/1"},
+				wantTotal: 2,
+			},
+			{
+				name:       "page keeps ranks and finds the player",
+				q:          LeaderboardQuery{SortBy: "wins", Offset: 1, Limit: 1, Player: "carol"},
+				want:       []string{"2 bob 4/2/2/0"},
+				wantTotal:  3,
+				wantPlayer: "3 carol 3/0/2/1",
+			},
+			{
+				name:      "player outside the window",
+				q:         LeaderboardQuery{SortBy: "wins", Since: window.Add(4 * time.Hour), Player: "alice"},
+				want:      []string{},
+				wantTotal: 0,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if tt.q.Limit == 0 {
+					tt.q.Limit = 100
+				}
+				board, err := db.GetLeaderboard(tt.q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := standings(board.Entries); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("leaderboard = %v, want %v", got, tt.want)
+				}
+				if board.Total != tt.wantTotal {
+					t.Fatalf("total = %d, want %d", board.Total, tt.wantTotal)
+				}
+				var player string
+				if board.Player != nil {
+					player = standings([]LeaderboardEntry{*board.Player})[0]
+				}
+				if player != tt.wantPlayer {
+					t.Fatalf("player = %q, want %q", player, tt.wantPlayer)
+				}
+			})
+		}
+
+		t.Run("by rating", func(t *testing.T) {
+			board, err := db.GetLeaderboard(LeaderboardQuery{SortBy: "rating", Limit: 100})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, e := range board.Entries {
+				if e.Rank != i+1 || (i > 0 && e.Rating > board.Entries[i-1].Rating) {
+					t.Fatalf("leaderboard not ranked by rating: %+v", board.Entries)
+				}
+			}
+			if len(board.Entries) != 3 || board.Entries[0].Username != "alice" {
+				t.Fatalf("leaderboard = %+v, want alice first", board.Entries)
+			}
+		})
+	})
+}
+
+// TestStoreDeleteArchivedGames archives the games before the window and checks
+// all-time standings and head-to-head totals still count them.
+func TestStoreDeleteArchivedGames(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		recordGames(t, db, leaderboardGames()...)
+		recordGames(t, db, finishedGame("old4", "bob", "alice", "draw", testStart.Add(3*time.Hour), 42))
+
+		allTime := LeaderboardQuery{SortBy: "wins", Limit: 100}
+		before, err := db.GetLeaderboard(allTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		history, err := db.ListPlayerGames(GameQuery{Username: "alice", Opponent: "bob", Limit: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want HeadToHeadTotals
+		for i := len(history) - 1; i >= 0; i-- {
+			want.add(history[i])
+		}
+
+		games, err := db.ListGamesBefore(testStart.Add(24*time.Hour), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, g := range games {
+			ids = append(ids, g.Game.ID)
+		}
+		if want := []string{"old1", "old2", "old3", "old4"}; !reflect.DeepEqual(ids, want) {
+			t.Fatalf("games to archive = %v, want %v", ids, want)
+		}
+
+		// Archiving a batch twice, as after a crash, counts it once
+		for i := 0; i < 2; i++ {
+			if err := db.DeleteArchivedGames(games); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if game, err := db.GetGame("old1"); err != nil || game != nil {
+			t.Fatalf("archived game = %+v, %v; want it deleted", game, err)
+		}
+		left, err := db.ListPlayerGames(GameQuery{Username: "alice", Limit: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := gameIDs(left); !reflect.DeepEqual(got, []string{"new3"}) {
+			t.Fatalf("alice's games = %v, want [new3]", got)
+		}
+
+		after, err := db.GetLeaderboard(allTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := standings(after.Entries), standings(before.Entries); !reflect.DeepEqual(got, want) {
+			t.Fatalf("all-time leaderboard after archiving = %v, want %v", got, want)
+		}
+		noBots, err := db.GetLeaderboard(LeaderboardQuery{SortBy: "wins", ExcludeBots: true, Limit: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"1 alice 4/2/0/2", "2 bob 3/1/1/1", "3 carol 3/0/2/1"}; !reflect.DeepEqual(standings(noBots.Entries), want) {
+			t.Fatalf("all-time leaderboard without bots = %v, want %v", standings(noBots.Entries), want)
+		}
+
+		got, err := db.ArchivedHeadToHead("alice", "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("archived head-to-head = %+v, want %+v", got, want)
+		}
+		if got.Games != 2 || got.WinsA != 1 || got.Draws != 1 {
+			t.Fatalf("archived head-to-head = %+v, want 2 games, 1 win for alice and 1 draw", got)
+		}
+		swapped, err := db.ArchivedHeadToHead("bob", "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if swapped != want.swap() {
+			t.Fatalf("archived head-to-head from bob's side = %+v, want %+v", swapped, want.swap())
+		}
+		none, err := db.ArchivedHeadToHead("alice", "dave")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if none != (HeadToHeadTotals{}) {
+			t.Fatalf("archived head-to-head with a stranger = %+v, want none", none)
+		}
+	})
+}
+
+func TestStoreAnonymizePlayer(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		games := leaderboardGames()
+		for _, game := range games {
+			event := newGameEvent(eventschema.GameCompleted, game, 1)
+			event.GameResult = game.Winner
+			if _, err := db.RecordResult(game, event); err != nil {
+				t.Fatal(err)
+			}
+		}
+		archived, err := db.ListGamesBefore(testStart.Add(24*time.Hour), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.DeleteArchivedGames(archived); err != nil {
+			t.Fatal(err)
+		}
+		headToHead, err := db.ArchivedHeadToHead("alice", "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.ClaimPlayerToken("alice", "hash"); err != nil {
+			t.Fatal(err)
+		}
+
+		const alias = "deleted-1"
+		found, err := db.AnonymizePlayer("alice", alias)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found {
+			t.Fatal("alice not found")
+		}
+
+		games2, err := db.ListPlayerGames(GameQuery{Username: "carol", Limit: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(games2) != 2 || games2[0].ID != "new3" || games2[0].Opponent != alias || games2[0].Result != "draw" {
+			t.Fatalf("carol's games = %+v, want new3 against %s first", games2, alias)
+		}
+		if mine, err := db.ListPlayerGames(GameQuery{Username: "alice", Limit: 100}); err != nil || len(mine) != 0 {
+			t.Fatalf("alice's games = %v, %v; want none", mine, err)
+		}
+
+		got, err := db.ArchivedHeadToHead(alias, "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != headToHead {
+			t.Fatalf("archived head-to-head under the alias = %+v, want %+v", got, headToHead)
+		}
+
+		board, err := db.GetLeaderboard(LeaderboardQuery{SortBy: "wins", Limit: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range board.Entries {
+			if e.Username == "alice" || e.Username == alias {
+				t.Fatalf("leaderboard = %v, want no entry for a deleted player", standings(board.Entries))
+			}
+		}
+		stats, err := db.GetPlayerStats("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(stats["wins"], stats["draws"]) != "0 0" {
+			t.Fatalf("alice's stats = %v, want none", stats)
+		}
+		if hash, err := db.PlayerTokenHash("alice"); err != nil || hash != "" {
+			t.Fatalf("alice's token hash = %q, %v; want none", hash, err)
+		}
+
+		pending, err := db.PendingEvents(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) != len(games) {
+			t.Fatalf("%d pending events, want %d", len(pending), len(games))
+		}
+		data, err := json.Marshal(pending)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "alice") || !strings.Contains(string(data), alias) {
+			t.Fatalf("pending events = %s, want alice replaced by %s", data, alias)
+		}
+
+		if found, err := db.AnonymizePlayer("alice", "deleted-2"); err != nil || found {
+			t.Fatalf("deleting alice again: found = %v, %v; want false", found, err)
+		}
+	})
+}
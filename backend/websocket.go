@@ -2,55 +2,89 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// Time allowed to write a message to the peer.
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong (or any message) from the peer.
+	pongWait = 60 * time.Second
+
+	// Send pings to peer with this period. Must be less than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+)
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development
-	},
+	Subprotocols:    Subprotocols(),
 }
 
 type WSConnection struct {
-	conn *websocket.Conn
+	conn  *websocket.Conn
+	codec Codec
 }
 
-func NewWSConnection(w http.ResponseWriter, r *http.Request) (*WSConnection, error) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+func NewWSConnection(w http.ResponseWriter, r *http.Request, origins *OriginPolicy) (*WSConnection, error) {
+	u := upgrader
+	u.CheckOrigin = origins.CheckOrigin
+	conn, err := u.Upgrade(w, r, nil)
 	if err != nil {
 		return nil, err
 	}
-	return &WSConnection{conn: conn}, nil
+
+	wsConn := &WSConnection{conn: conn, codec: CodecFor(conn.Subprotocol())}
+
+	// Any pong pushes the read deadline out again; a peer that stops answering
+	// pings fails its next read and goes through the normal disconnect flow.
+	wsConn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return wsConn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	return wsConn, nil
 }
 
-func (wsc *WSConnection) ReadMessage() (string, []byte, error) {
-	_, data, err := wsc.conn.ReadMessage()
-	if err != nil {
-		return "", nil, err
-	}
+// InboundMessage is a message received from a client. RequestID is optional
+// and is echoed back on the ack or error the request produces.
+type InboundMessage struct {
+	Type      string          `json:"type"`
+	RequestID string          `json:"requestId,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+}
 
-	var msg Message
-	err = json.Unmarshal(data, &msg)
+func (wsc *WSConnection) ReadMessage() (*InboundMessage, error) {
+	_, data, err := wsc.conn.ReadMessage()
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 
-	payload, _ := json.Marshal(msg.Payload)
-	return msg.Type, payload, nil
+	return wsc.codec.Decode(data)
 }
 
 func (wsc *WSConnection) WriteMessage(msg interface{}) error {
-	data, err := json.Marshal(msg)
+	data, err := wsc.codec.Encode(msg)
 	if err != nil {
 		return err
 	}
-	return wsc.conn.WriteMessage(websocket.TextMessage, data)
+	wsc.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return wsc.conn.WriteMessage(wsc.codec.FrameType(), data)
+}
+
+func (wsc *WSConnection) WritePing() error {
+	return wsc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+}
+
+func (wsc *WSConnection) WriteClose() error {
+	return wsc.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
 }
 
 func (wsc *WSConnection) Close() error {
@@ -61,73 +95,59 @@ func (wsc *WSConnection) SetReadDeadline(t time.Time) error {
 	return wsc.conn.SetReadDeadline(t)
 }
 
+func (wsc *WSConnection) SetReadLimit(limit int64) {
+	wsc.conn.SetReadLimit(limit)
+}
+
 // HandleWebSocket handles WebSocket connections
-func HandleWebSocket(hub *Hub) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		wsConn, err := NewWSConnection(w, r)
+func HandleWebSocket(hub *Hub, guard *AbuseGuard, origins *OriginPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w, r := c.Writer, c.Request
+		ip := c.ClientIP()
+		if !guard.OpenConnection(ip) {
+			http.Error(w, "Too many connections", http.StatusTooManyRequests)
+			return
+		}
+		defer guard.CloseConnection(ip)
+
+		wsConn, err := NewWSConnection(w, r, origins)
 		if err != nil {
 			log.Println("WebSocket upgrade error:", err)
 			return
 		}
 		defer wsConn.Close()
+		wsConn.SetReadLimit(guard.cfg.MaxFrameBytes)
 
-		client := &Client{
-			hub:      hub,
-			conn:     wsConn,
-			send:     make(chan interface{}, 256),
-			username: "",
-			gameID:   "",
-		}
+		client := newClient(hub, wsConn)
+		defer guard.ForgetClient(client)
+		// Clients connecting with ?moves=delta get moves without the board
+		client.deltaMoves = r.URL.Query().Get("moves") == "delta"
 
 		hub.RegisterClient(client)
 		defer hub.UnregisterClient(client)
 
-		// Send goroutine
-		go func() {
-			for msg := range client.send {
-				if err := wsConn.WriteMessage(msg); err != nil {
-					log.Println("Write error:", err)
-					return
-				}
-			}
-		}()
+		go client.writePump(wsConn)
 
 		// Read goroutine
 		for {
-			msgType, payload, err := wsConn.ReadMessage()
+			msg, err := wsConn.ReadMessage()
 			if err != nil {
+				if errors.Is(err, websocket.ErrReadLimit) {
+					guard.strike("frame_size", ip)
+				}
 				log.Println("Read error:", err)
 				return
 			}
 
-			log.Printf("Received message type: %s from %s\n", msgType, client.username)
-
-			switch msgType {
-			case "register":
-				var registerMsg struct {
-					Username string `json:"username"`
-				}
-				json.Unmarshal(payload, &registerMsg)
-				client.username = registerMsg.Username
-				hub.RequestMatchmaking(registerMsg.Username, client)
-				log.Printf("Player registered: %s\n", registerMsg.Username)
-
-			case "game_move":
-				var moveMsg GameMoveMessage
-				json.Unmarshal(payload, &moveMsg)
-				hub.HandleGameMove(client, moveMsg.Column)
-
-			case "rejoin":
-				var rejoinMsg struct {
-					GameID string `json:"gameId"`
+			if !guard.AllowMessage(client, ip) {
+				if guard.Banned(ip) {
+					return
 				}
-				json.Unmarshal(payload, &rejoinMsg)
-				client.gameID = rejoinMsg.GameID
-				log.Printf("Player %s rejoining game %s\n", client.username, rejoinMsg.GameID)
-
-			default:
-				log.Printf("Unknown message type: %s\n", msgType)
+				client.sendError(msg.RequestID, "Rate limit exceeded")
+				continue
 			}
+
+			hub.HandleMessage(client, msg)
 		}
 	}
 }
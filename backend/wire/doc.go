@@ -0,0 +1,5 @@
+// Package wire holds the protobuf messages of the "protobuf" WebSocket
+// subprotocol.
+package wire
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative wire.proto
@@ -0,0 +1,96 @@
+package eventschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnsupportedVersion is returned for events newer than this package
+// understands. Consumers should hold on to them until they are upgraded
+// rather than guess at their meaning.
+type ErrUnsupportedVersion struct {
+	Version int
+}
+
+func (e ErrUnsupportedVersion) Error() string {
+	return fmt.Sprintf("unsupported event schema version %d (newest known is %d)", e.Version, CurrentVersion)
+}
+
+// eventV1 is an event as published before schema versions existed.
+type eventV1 struct {
+	EventType  string    `json:"eventType"`
+	GameID     string    `json:"gameId"`
+	Player     string    `json:"player"`
+	Opponent   string    `json:"opponent"`
+	Action     string    `json:"action"`
+	Column     int       `json:"column,omitempty"`
+	Row        int       `json:"row,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+	IsBot      bool      `json:"isBot"`
+	GameResult string    `json:"gameResult,omitempty"`
+	Duration   int       `json:"duration,omitempty"`
+}
+
+// Encode marshals event at CurrentVersion.
+func Encode(event Event) ([]byte, error) {
+	event.SchemaVersion = CurrentVersion
+	return json.Marshal(event)
+}
+
+// Decode unmarshals an event of any known version and upcasts it to
+// CurrentVersion. Events without a schemaVersion are version 1.
+func Decode(data []byte) (Event, error) {
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return Event{}, err
+	}
+
+	switch header.SchemaVersion {
+	case 0, 1:
+		var old eventV1
+		if err := json.Unmarshal(data, &old); err != nil {
+			return Event{}, err
+		}
+		return upcastV1(old, data), nil
+	case CurrentVersion:
+		var event Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return Event{}, err
+		}
+		return event, nil
+	default:
+		return Event{}, ErrUnsupportedVersion{Version: header.SchemaVersion}
+	}
+}
+
+// upcastV1 converts a version 1 event. Version 1 had no event IDs: a
+// game_completed gets the ID the backend now gives it, so the same result
+// seen before and after the upgrade is counted once, and other events get
+// one derived from their content.
+func upcastV1(old eventV1, data []byte) Event {
+	id := uuid.NewSHA1(uuid.NameSpaceURL, data).String()
+	if old.EventType == GameCompleted && old.GameID != "" {
+		id = StableID(old.GameID, GameCompleted)
+	}
+
+	return Event{
+		EventID:       id,
+		SchemaVersion: CurrentVersion,
+		EventType:     old.EventType,
+		GameID:        old.GameID,
+		Player:        old.Player,
+		Opponent:      old.Opponent,
+		Action:        old.Action,
+		Column:        old.Column,
+		Row:           old.Row,
+		Timestamp:     old.Timestamp,
+		IsBot:         old.IsBot,
+		GameResult:    old.GameResult,
+		Duration:      old.Duration,
+	}
+}
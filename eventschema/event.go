@@ -0,0 +1,65 @@
+// Package eventschema defines the game events the backend publishes and the
+// analytics service consumes, so both sides share one definition.
+//
+// Every event carries a schema version. Decode upcasts events written with an
+// older version to the current one; changing the JSON of an existing field
+// needs a new version and an upcaster rather than an edit in place.
+package eventschema
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CurrentVersion is the schema version Encode writes.
+//
+//	1: the original flat event, with no envelope
+//	2: adds eventId, schemaVersion, seq, moveNumber, publishedAt and reason
+const CurrentVersion = 2
+
+// Event types.
+const (
+	MatchmakingJoined  = "matchmaking_joined"
+	MatchmakingLeft    = "matchmaking_left"
+	BotFallback        = "bot_fallback"
+	GameStarted        = "game_started"
+	GameMove           = "game_move"
+	GameCompleted      = "game_completed"
+	PlayerDisconnected = "player_disconnected"
+	PlayerReconnected  = "player_reconnected"
+)
+
+// ReasonDisconnectForfeit is the reason of a game_completed event for a game
+// lost by a player who disconnected and didn't come back in time.
+const ReasonDisconnectForfeit = "disconnect_forfeit"
+
+// Event is a game event at CurrentVersion. EventID, SchemaVersion, GameID,
+// Seq, Timestamp (when it happened) and PublishedAt (when it was sent) form
+// the envelope shared by every event type.
+type Event struct {
+	EventID       string     `json:"eventId,omitempty"`
+	SchemaVersion int        `json:"schemaVersion,omitempty"`
+	EventType     string     `json:"eventType"`
+	GameID        string     `json:"gameId"`
+	Seq           int64      `json:"seq,omitempty"`
+	Player        string     `json:"player"`
+	Opponent      string     `json:"opponent"`
+	Action        string     `json:"action"`
+	MoveNumber    int        `json:"moveNumber,omitempty"`
+	Column        int        `json:"column,omitempty"`
+	Row           int        `json:"row,omitempty"`
+	Timestamp     time.Time  `json:"timestamp"`
+	PublishedAt   *time.Time `json:"publishedAt,omitempty"`
+	IsBot         bool       `json:"isBot"`
+	GameResult    string     `json:"gameResult,omitempty"`
+	Duration      int        `json:"duration,omitempty"`
+	Reason        string     `json:"reason,omitempty"`
+}
+
+// StableID derives an event ID from what identifies the event, so the same
+// event always gets the same ID.
+func StableID(parts ...string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("4-in-a-row:event/"+strings.Join(parts, "/")+"/")).String()
+}
@@ -0,0 +1,123 @@
+package eventschema
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// currentFields are the JSON names of Event at CurrentVersion. A field may be
+// added here, but renaming or removing one, or changing its type, breaks
+// consumers and needs a new version with an upcaster instead.
+var currentFields = []string{
+	"eventId", "schemaVersion", "eventType", "gameId", "seq", "player",
+	"opponent", "action", "moveNumber", "column", "row", "timestamp",
+	"publishedAt", "isBot", "gameResult", "duration", "reason",
+}
+
+func readGolden(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestFieldsAreFrozen(t *testing.T) {
+	typ := reflect.TypeOf(Event{})
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		names = append(names, strings.Split(typ.Field(i).Tag.Get("json"), ",")[0])
+	}
+	if !reflect.DeepEqual(names, currentFields) {
+		t.Fatalf("Event fields changed:\n got  %v\n want %v", names, currentFields)
+	}
+}
+
+// TestRoundTripCurrent decodes events written at the current version and
+// checks that encoding them again gives back exactly the same JSON.
+func TestRoundTripCurrent(t *testing.T) {
+	for _, name := range []string{"v2_game_move.json", "v2_game_completed.json", "v2_matchmaking_left.json"} {
+		t.Run(name, func(t *testing.T) {
+			data := readGolden(t, name)
+
+			event, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := Encode(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want, got map[string]interface{}
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(encoded, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("round trip changed the event:\n got  %s\n want %s", encoded, data)
+			}
+		})
+	}
+}
+
+func TestDecodeUpcastsV1(t *testing.T) {
+	completed, err := Decode(readGolden(t, "v1_game_completed.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Event{
+		EventID:       "40587842-5bed-57b9-b5ce-93dd92bcbb1b",
+		SchemaVersion: CurrentVersion,
+		EventType:     GameCompleted,
+		GameID:        "3f2c9a4e-7d1b-4c55-9a0e-1b2c3d4e5f60",
+		Player:        "alice",
+		Opponent:      "Bot",
+		Timestamp:     time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC),
+		IsBot:         true,
+		GameResult:    "alice",
+		Duration:      94,
+	}
+	if !reflect.DeepEqual(completed, want) {
+		t.Fatalf("got %+v\nwant %+v", completed, want)
+	}
+
+	data := readGolden(t, "v1_game_move.json")
+	move, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if move.EventID == "" || move.Column != 3 || move.Row != 5 || move.Action != "move" {
+		t.Fatalf("unexpected upcast move: %+v", move)
+	}
+	again, _ := Decode(data)
+	if again.EventID != move.EventID {
+		t.Fatalf("v1 event IDs are not stable: %s then %s", move.EventID, again.EventID)
+	}
+}
+
+// TestStableID pins the ID scheme: changing it would make redelivered and
+// upcast events look new to consumers.
+func TestStableID(t *testing.T) {
+	got := StableID("3f2c9a4e-7d1b-4c55-9a0e-1b2c3d4e5f60", GameCompleted)
+	if got != "40587842-5bed-57b9-b5ce-93dd92bcbb1b" {
+		t.Fatalf("StableID changed: %s", got)
+	}
+}
+
+func TestDecodeRejectsNewerVersions(t *testing.T) {
+	_, err := Decode([]byte(`{"schemaVersion":3,"eventType":"game_move"}`))
+	var unsupported ErrUnsupportedVersion
+	if !errors.As(err, &unsupported) || unsupported.Version != 3 {
+		t.Fatalf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
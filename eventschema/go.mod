@@ -0,0 +1,5 @@
+module eventschema
+
+go 1.21
+
+require github.com/google/uuid v1.5.0
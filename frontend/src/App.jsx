@@ -61,6 +61,10 @@ function App() {
         case 'error':
           setError(data.payload.message)
           break
+        case 'player_token':
+          // Proves ownership of the username for profile changes
+          localStorage.setItem(`playerToken:${data.payload.username}`, data.payload.token)
+          break
         default:
           console.log('Unknown message type:', data.type)
       }
@@ -65,7 +65,7 @@ function Leaderboard() {
                 <div className="stat-col">{player.wins}</div>
                 <div className="stat-col">{player.losses}</div>
                 <div className="stat-col">{player.draws}</div>
-                <div className="stat-col">{player.winRate}</div>
+                <div className="stat-col">{player.winRate.toFixed(2)}%</div>
               </div>
             ))}
           </div>